@@ -12,6 +12,9 @@ func NewConfigCommand(f *factory.Factory) (cmd *cobra.Command) {
 			newViewCommand,
 			newPathCommand,
 			newEditCommand,
+			newConvertCommand,
+			newHistoryCommand,
+			newRestoreCommand,
 		),
 		factory.WithHelp("dfctl config actions", "interact with the current dfctl config"),
 	)
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newConvertCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("convert",
+		factory.WithHelp("convert the configuration into another format", "rewrites the $DFCTL_CONFIG file in the given format and verifies that the converted config is semantically identical"),
+	)
+	to := cmd.Flags().StringP("to", "t", "", fmt.Sprintf("--to | -t [ %s ]", strings.Join(zsh.Formats, " | ")))
+	_ = cmd.MarkFlagRequired("to")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := zsh.ConfigFile()
+		converted, err := zsh.Convert(path, "."+strings.TrimPrefix(strings.ToLower(*to), "."))
+		if err != nil {
+			return err
+		}
+		if converted == path {
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s already is a %s config\n", path, *to)
+			return err
+		}
+
+		if _, err = fmt.Fprintf(cmd.OutOrStdout(), "converted %s -> %s\n", path, converted); err != nil {
+			return err
+		}
+		if env.GetVars().IsSet("DFCTL_CONFIG") {
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "$DFCTL_CONFIG still points to %s; update it to %s\n", path, converted)
+		}
+		return err
+	}
+
+	return cmd
+}
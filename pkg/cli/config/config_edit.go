@@ -1,61 +1,148 @@
 package config
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/alex-held/dfctl/pkg/diff"
+	"github.com/alex-held/dfctl/pkg/editor"
 	"github.com/alex-held/dfctl/pkg/factory"
 	"github.com/alex-held/dfctl/pkg/zsh"
 )
 
+// commentPrefix marks the lines dfctl adds to the editor buffer; they are stripped before parsing
+const commentPrefix = "# dfctl: "
+
 func newEditCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd = f.NewCommand("edit",
-		factory.WithHelp("edit the current configuration", "opens an editor buffer to edit the current $DFCTL_CONFIG_FILE and saves the modified buffer back to disk"),
+		factory.WithHelp("edit the current configuration", "opens $VISUAL / $EDITOR to edit the current $DFCTL_CONFIG_FILE, validates the modified buffer and saves it back to disk after confirmation"),
 	)
+	yes := cmd.Flags().BoolP("yes", "y", false, "save the edited config without asking for confirmation")
+
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		file, err := os.CreateTemp("", "dfctl-config-*.yaml")
-		defer file.Close()
+		return runEditCommand(f, cmd.OutOrStdout(), *yes)
+	}
 
-		if err != nil {
+	return cmd
+}
+
+func runEditCommand(f *factory.Factory, out io.Writer, yes bool) (err error) {
+	path := zsh.ConfigFile()
+	ext := filepath.Ext(path)
+	withExt := func(formatter *zsh.ConfigFormatter) {
+		formatter.ConfigFileType = ext
+	}
+
+	cfg, err := zsh.Load()
+	if err != nil {
+		return err
+	}
+	original, err := cfg.Format(withExt)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "dfctl-config-*"+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	buffer := original
+	var edited *zsh.ConfigSpec
+	var validationErr error
+	for {
+		if err = os.WriteFile(file.Name(), []byte(buffer), 0600); err != nil {
 			return err
 		}
-		cfg, err := zsh.Load()
-		if err != nil {
+		if err = editor.Edit(f.Streams, file.Name()); err != nil {
 			return err
 		}
-
-		formatted, err := cfg.Format()
+		data, err := os.ReadFile(file.Name())
 		if err != nil {
 			return err
 		}
-		_, err = file.WriteString(formatted)
 
-		if err != nil {
-			return err
+		content := stripComments(string(data))
+		if validationErr != nil && content == stripComments(buffer) {
+			return fmt.Errorf("aborted editing the config, the buffer is still invalid: %w", validationErr)
 		}
 
-		vimCommand := exec.Command("vim", file.Name())
-		vimCommand.Stdout = os.Stdout
-		vimCommand.Stderr = os.Stderr
-		vimCommand.Stdin = os.Stdin
+		if edited, validationErr = zsh.Parse([]byte(content), ext); validationErr == nil {
+			break
+		}
+		buffer = errorComment(validationErr) + content
+	}
 
-		if err = vimCommand.Start(); err != nil {
+	formatted, err := edited.Format(withExt)
+	if err != nil {
+		return err
+	}
+
+	changes := diff.Unified(path, path+" (edited)", original, formatted, 3)
+	if changes == "" {
+		_, err = fmt.Fprintln(out, "no changes")
+		return err
+	}
+	if _, err = fmt.Fprint(out, changes); err != nil {
+		return err
+	}
+
+	if !yes {
+		ok, err := confirm(f.Streams.In, out, fmt.Sprintf("save changes to %s?", path))
+		if err != nil {
 			return err
 		}
-		if err = vimCommand.Wait(); err != nil {
+		if !ok {
+			_, err = fmt.Fprintln(out, "discarded changes")
 			return err
 		}
+	}
 
-		patchedCfg, err := zsh.LoadFromPath(file.Name())
-		if err != nil {
-			return err
-		}
+	return zsh.Save(edited.WithOriginOf(cfg))
+}
 
-		err = zsh.Save(patchedCfg)
-		return err
+func errorComment(err error) string {
+	sb := &strings.Builder{}
+	sb.WriteString(commentPrefix + "the edited config is invalid and has not been saved\n")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		sb.WriteString(commentPrefix + line + "\n")
 	}
+	sb.WriteString(commentPrefix + "fix the config and save, or quit without changes to abort\n")
+	return sb.String()
+}
 
-	return cmd
+func stripComments(content string) string {
+	var lines []string
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if !strings.HasPrefix(line, commentPrefix) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func confirm(in io.Reader, out io.Writer, question string) (ok bool, err error) {
+	if _, err = fmt.Fprintf(out, "%s [y/N] ", question); err != nil {
+		return false, err
+	}
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
 }
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/diff"
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newHistoryCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("history",
+		factory.WithHelp("list the snapshots of the configuration", "lists the snapshots taken before the $DFCTL_CONFIG file got overwritten, newest first, together with the changes that replaced them"),
+	)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := zsh.ConfigFile()
+		snapshots, err := zsh.History(path)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "no history")
+			return err
+		}
+
+		var data []interface{}
+		next := path
+		for _, snapshot := range snapshots {
+			entry := historyEntry{Snapshot: snapshot}
+			entry.Stats, entry.Err = snapshot.Summary(next)
+			data = append(data, entry)
+			next = snapshot.Path
+		}
+
+		sink := out.NewTableSink(cmd.OutOrStdout(), historyFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"ID", "Date", "Changes"})
+		})
+		return sink.WriteAndFlush(data)
+	}
+	return cmd
+}
+
+type historyEntry struct {
+	zsh.Snapshot
+	Stats diff.Stats
+	Err   error
+}
+
+type historyFormatter struct{}
+
+func (historyFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	entry := v.(historyEntry)
+
+	values = append(values, entry.ID)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+
+	values = append(values, entry.Time.Local().Format("2006-01-02 15:04:05"))
+	options = append(options, out.ColorFormat(tablewriter.Colors{}))
+
+	switch {
+	case entry.Err != nil:
+		values = append(values, entry.Err.Error())
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	case !entry.Stats.HasChanges():
+		values = append(values, "no changes")
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+	default:
+		values = append(values, entry.Stats.String())
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor}))
+	}
+	return values, options
+}
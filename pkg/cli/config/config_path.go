@@ -5,9 +5,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/alex-held/dfctl-kit/pkg/env"
-
 	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
 )
 
 func newPathCommand(f *factory.Factory) (cmd *cobra.Command) {
@@ -15,7 +14,7 @@ func newPathCommand(f *factory.Factory) (cmd *cobra.Command) {
 		factory.WithHelp("view the current configuation file path", "displays a the full path of the $DFCTL_CONFIG file"),
 	)
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
-		path := env.ConfigFile()
+		path := zsh.ConfigFile()
 		_, err = fmt.Fprintln(cmd.OutOrStdout(), path)
 		return err
 	}
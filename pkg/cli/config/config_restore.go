@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newRestoreCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("restore [id]",
+		factory.WithHelp("restore a snapshot of the configuration", "replaces the $DFCTL_CONFIG file with the snapshot identified by [id] or an unique prefix of it; see `dfctl config history`"),
+	)
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		snapshot, err := zsh.Restore(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "restored snapshot %s\n", snapshot.ID)
+		return err
+	}
+	return cmd
+}
+
+// NewUndoCommand creates the `dfctl undo` command, which restores the most recent config snapshot
+func NewUndoCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("undo",
+		factory.WithHelp("undo the last change of the configuration", "restores the most recent snapshot of the $DFCTL_CONFIG file; running undo again restores the snapshot before it"),
+	)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		snapshot, err := zsh.Undo()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "restored snapshot %s\n", snapshot.ID)
+		return err
+	}
+	return cmd
+}
@@ -6,8 +6,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/alex-held/dfctl-kit/pkg/env"
-
 	"github.com/alex-held/dfctl/pkg/factory"
 	"github.com/alex-held/dfctl/pkg/zsh"
 )
@@ -22,7 +20,7 @@ func newViewCommand(f *factory.Factory) (cmd *cobra.Command) {
 			return err
 		}
 		formatted, err := cfg.Format(func(f *zsh.ConfigFormatter) {
-			f.ConfigFileType = filepath.Ext(env.ConfigFile())
+			f.ConfigFileType = filepath.Ext(zsh.ConfigFile())
 		})
 		if err != nil {
 			return err
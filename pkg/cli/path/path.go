@@ -0,0 +1,21 @@
+package path
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func NewPathCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("path [command]",
+		factory.WithSubcommands(
+			newAddCommand,
+			newRemoveCommand,
+			newListCommand,
+			newDoctorCommand,
+		),
+		factory.WithHelp("manage the directories added to $PATH", "manage the directories the generated startup files add to $PATH, in front of or after the system $PATH, optionally only on some machines"),
+	)
+
+	return cmd
+}
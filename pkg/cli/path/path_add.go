@@ -0,0 +1,51 @@
+package path
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newAddCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("add [dir]",
+		factory.WithHelp("add a directory to $PATH", "adds [dir] after the system $PATH, or in front of it with --prepend.\n[dir] may reference variables like $GOBIN or facts like ${{ home }}; adding a directory again with the same condition updates its position"),
+	)
+	prepend := cmd.Flags().Bool("prepend", false, "add the directory in front of the system $PATH")
+	when := cmd.Flags().String("when", "", "condition restricting the machines the directory is added on, e.g. 'hostname =~ \"^work-\"'")
+	goos := cmd.Flags().String("os", "", "only add the directory on this os, e.g. darwin or linux")
+
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		entry := zsh.PathEntry{Value: zsh.Value{Value: args[0], When: condition(*when, *goos)}, Position: zsh.PATH_APPEND}
+		if *prepend {
+			entry.Position = zsh.PATH_PREPEND
+		}
+		if _, err = zsh.CurrentFacts().Eval(entry.When); err != nil {
+			return err
+		}
+
+		if err = zsh.Update(func(cfg *zsh.ConfigSpec) error {
+			return cfg.Configs.Paths.Add(entry)
+		}); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "added %s (%s)\n", entry.Value.Value, entry.Position)
+		return err
+	}
+	return cmd
+}
+
+// condition combines the --when condition with the --os shorthand
+func condition(when, goos string) string {
+	switch {
+	case goos == "":
+		return when
+	case when == "":
+		return fmt.Sprintf("os == %q", goos)
+	default:
+		return fmt.Sprintf("(%s) && os == %q", when, goos)
+	}
+}
@@ -0,0 +1,68 @@
+package path
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+var ErrPathIssues = fmt.Errorf("found path issues")
+
+func newDoctorCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("doctor",
+		factory.WithHelp("check the directories added to $PATH", "checks the directories added to $PATH on this machine for missing directories, duplicates and prepended directories whose executables shadow the ones of the system $PATH.\nexits non-zero when issues were found"),
+	)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		cfg, err := zsh.Load()
+		if err != nil {
+			return err
+		}
+		issues, err := zsh.DiagnosePaths(cfg.Configs.Paths, zsh.CurrentFacts())
+		if err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "no issues found")
+			return err
+		}
+
+		var data []interface{}
+		for _, issue := range issues {
+			data = append(data, issue)
+		}
+		sink := out.NewTableSink(cmd.OutOrStdout(), issueFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Path", "Issue", "Detail"})
+		})
+		if err = sink.WriteAndFlush(data); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d", ErrPathIssues, len(issues))
+	}
+	return cmd
+}
+
+type issueFormatter struct{}
+
+func (issueFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	issue := v.(zsh.PathIssue)
+
+	values = append(values, issue.Path)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+
+	values = append(values, issue.Kind)
+	switch issue.Kind {
+	case zsh.PathShadowing:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+	default:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	}
+
+	values = append(values, issue.Detail)
+	options = append(options, out.ColorFormat(tablewriter.Colors{}))
+	return values, options
+}
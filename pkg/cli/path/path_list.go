@@ -0,0 +1,75 @@
+package path
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newListCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("list",
+		factory.WithHelp("list the directories added to $PATH", "lists the configured directories in the order they get added to $PATH, together with their position and condition; directories whose condition does not hold on this machine are dimmed"),
+	)
+	cmd.Aliases = []string{"ls"}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		cfg, err := zsh.Load()
+		if err != nil {
+			return err
+		}
+		if len(cfg.Configs.Paths) == 0 {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "no paths")
+			return err
+		}
+
+		facts := zsh.CurrentFacts()
+		var data []interface{}
+		for _, entry := range cfg.Configs.Paths {
+			active, err := facts.Eval(entry.When)
+			if err != nil {
+				return err
+			}
+			data = append(data, pathEntry{PathEntry: entry, Active: active})
+		}
+
+		sink := out.NewTableSink(cmd.OutOrStdout(), pathFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Path", "Position", "When"})
+		})
+		return sink.WriteAndFlush(data)
+	}
+	return cmd
+}
+
+type pathEntry struct {
+	zsh.PathEntry
+	Active bool
+}
+
+type pathFormatter struct{}
+
+func (pathFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	entry := v.(pathEntry)
+
+	values = append(values, entry.Value.Value)
+	if entry.Active {
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor, tablewriter.Bold}))
+	} else {
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiBlackColor}))
+	}
+
+	values = append(values, string(entry.Position))
+	switch entry.Position {
+	case zsh.PATH_PREPEND:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+	default:
+		options = append(options, out.ColorFormat(tablewriter.Colors{}))
+	}
+
+	values = append(values, entry.When)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgCyanColor}))
+	return values, options
+}
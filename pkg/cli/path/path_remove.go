@@ -0,0 +1,28 @@
+package path
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newRemoveCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("remove [dir]",
+		factory.WithHelp("remove a directory from $PATH", "removes all entries of [dir], written as in `dfctl path list`, regardless of their condition"),
+	)
+	cmd.Aliases = []string{"rm"}
+	cmd.Args = cobra.ExactArgs(1)
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if err = zsh.Update(func(cfg *zsh.ConfigSpec) error {
+			return cfg.Configs.Paths.Remove(args[0])
+		}); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "removed %s\n", args[0])
+		return err
+	}
+	return cmd
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/alex-held/dfctl/pkg/cli/config"
 	"github.com/alex-held/dfctl/pkg/cli/extension"
+	"github.com/alex-held/dfctl/pkg/cli/path"
 	"github.com/alex-held/dfctl/pkg/cli/status"
 	"github.com/alex-held/dfctl/pkg/cli/version"
 	"github.com/alex-held/dfctl/pkg/cli/zsh/zsh"
@@ -27,9 +28,9 @@ import (
 func NewRootCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd = f.NewCommand("dfctl [flags] [command]",
 		factory.WithHelp("dotfiles and development environment manager", ""),
-		factory.WithGroupedSubcommands("module commands", zsh.NewZshCommand),
+		factory.WithGroupedSubcommands("module commands", zsh.NewZshCommand, zsh.NewSourceCommand, path.NewPathCommand),
 		factory.WithGroupedSubcommands("extension commands", extension.NewExtensionCommand),
-		factory.WithGroupedSubcommands("environment commands", config.NewConfigCommand),
+		factory.WithGroupedSubcommands("environment commands", config.NewConfigCommand, config.NewUndoCommand),
 		factory.WithGroupedSubcommands("status commands", status.NewStatusCommand, version.NewVersionCommand),
 	)
 
@@ -0,0 +1,39 @@
+package zsh
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newApplyCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("apply",
+		factory.WithHelp("sources the generated .zshrc from your ~/.zshrc", "inserts or updates a managed block in your ~/.zshrc, which sources the cached output of `dfctl zsh source --cached`, and renders the cache.\nthe cache is invalidated when the config, the templates or the installed plugins change, so apply only needs to run once.\ncontent outside of the managed block is preserved and a backup of ~/.zshrc is taken before it gets updated"),
+	)
+	check := cmd.Flags().Bool("check", false, "only check whether the managed block is up to date and exit non-zero if it is not")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		result, err := zsh.Apply(*check)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if result.UpToDate() {
+			_, err = fmt.Fprintf(out, "%s is up to date\n", result.Zshrc)
+			return err
+		}
+		if _, err = fmt.Fprintf(out, "updated the managed block in %s\n", result.Zshrc); err != nil {
+			return err
+		}
+		if result.Backup != "" {
+			_, err = fmt.Fprintf(out, "backup: %s\n", result.Backup)
+		}
+		return err
+	}
+
+	return cmd
+}
@@ -1,38 +1,199 @@
 package zsh
 
 import (
-	"github.com/rs/zerolog/log"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
 	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
 	"github.com/alex-held/dfctl/pkg/zsh"
 )
 
+var ErrInstallFailed = fmt.Errorf("failed to install")
+
 func newInstallCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd = &cobra.Command{
 		Use: "install",
 	}
+	frozen := cmd.Flags().Bool("frozen", false, "install the plugins and themes at the commits recorded in "+zsh.LockfilePath()+" and fail if it is out of date")
+	jobs := cmd.Flags().IntP("jobs", "j", zsh.InstallConcurrency, "number of plugins and themes to install at the same time")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		return runInstallCommand(f)
+		return runInstallCommand(f, cmd.OutOrStdout(), *frozen, *jobs)
 	}
 	return cmd
 }
 
-func runInstallCommand(f *factory.Factory) (err error) {
-	installables := zsh.ListInstallables()
+func runInstallCommand(f *factory.Factory, w io.Writer, frozen bool, jobs int) (err error) {
+	// plugins bundled with oh-my-zsh come with it and need no installation
+	var installables []zsh.Installable
+	for _, installable := range zsh.ListInstallables() {
+		if _, bundled := installable.(*zsh.OMZPlugin); !bundled {
+			installables = append(installables, installable)
+		}
+	}
+	if frozen {
+		lock, err := zsh.LoadLockfile()
+		if err != nil {
+			return err
+		}
+		if err = checkoutLocked(lock, installables); err != nil {
+			return err
+		}
+	}
+
+	var pending []zsh.Installable
+	results := map[zsh.Installable]zsh.InstallResult{}
 	for _, installable := range installables {
-		if !installable.IsInstalled() {
+		if installable.IsInstalled() {
+			results[installable] = zsh.InstallResult{Installed: false}
+			continue
+		}
+		pending = append(pending, installable)
+	}
+
+	progress := out.NewProgress(f.Streams.Err)
+	for installable, result := range zsh.InstallConcurrently(jobs, func(i zsh.Installable, state zsh.InstallState, result zsh.InstallResult) {
+		_ = progress.Set(i.Id(), formatProgress(i, state, result))
+	}, pending...) {
+		results[installable] = result
+	}
+
+	if err = printInstallSummary(w, installables, results); err != nil {
+		return err
+	}
 
-			log.Debug().Msgf("installing %v %s into %s", installable.GetKind(), installable.Id(), installable.Path())
-			result := installable.Install()
-			if result.Err != nil {
-				log.Error().Err(result.Err).Msgf("failed to install %v %s into %s", installable.GetKind(), installable.Id(), installable.Path())
-				return result.Err
+	failed := 0
+	for _, installable := range installables {
+		if results[installable].Err != nil {
+			failed++
+		}
+	}
+	if !frozen {
+		if err = zsh.UpdateLockfile(func(lock *zsh.Lockfile) error {
+			for _, installable := range installables {
+				if results[installable].Err != nil {
+					continue
+				}
+				if err := lock.Record(installable); err != nil {
+					return err
+				}
 			}
-			log.Debug().Bool("was_installed", result.Installed).Msgf("%v %s", installable.GetKind(), installable.Id())
+			lock.Prune(installables)
+			return nil
+		}); err != nil {
+			return err
 		}
-		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w %d of %d plugins and themes", ErrInstallFailed, failed, len(installables))
 	}
 	return nil
 }
+
+// checkoutLocked pins all installables to their locked commits and checks those out for the installed ones
+func checkoutLocked(lock *zsh.Lockfile, installables []zsh.Installable) (err error) {
+	commits := map[zsh.Installable]string{}
+	for _, installable := range installables {
+		if commits[installable], err = lock.Pin(installable); err != nil {
+			return err
+		}
+	}
+	for _, installable := range installables {
+		if commits[installable] == "" || !installable.IsInstalled() {
+			continue
+		}
+		if _, err = zsh.Checkout(installable.Path(), commits[installable]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatProgress(i zsh.Installable, state zsh.InstallState, result zsh.InstallResult) string {
+	name := fmt.Sprintf("%v %s", i.GetKind(), i.Id())
+	switch {
+	case state == zsh.InstallPending:
+		return "  " + name
+	case state == zsh.InstallRunning:
+		return "… " + name + " installing"
+	case result.Err != nil:
+		return "✗ " + name + " failed"
+	case result.Installed:
+		return "✓ " + name + " installed"
+	default:
+		return "- " + name + " skipped"
+	}
+}
+
+type installEntry struct {
+	zsh.InstallResult
+	ID   string
+	Kind string
+}
+
+func printInstallSummary(w io.Writer, installables []zsh.Installable, results map[zsh.Installable]zsh.InstallResult) (err error) {
+	var entries []installEntry
+	for _, installable := range installables {
+		entries = append(entries, installEntry{InstallResult: results[installable], ID: installable.Id(), Kind: installable.GetKind().String()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return installStatus(entries[i].InstallResult) < installStatus(entries[j].InstallResult)
+	})
+
+	var data []interface{}
+	for _, entry := range entries {
+		data = append(data, entry)
+	}
+	sink := out.NewTableSink(w, installFormatter{}, func(t *tablewriter.Table) {
+		t.SetHeader([]string{"Name", "Kind", "Status", "Error"})
+	})
+	return sink.WriteAndFlush(data)
+}
+
+// installStatus returns the status of an install result, in the order they are listed in the summary
+func installStatus(result zsh.InstallResult) string {
+	switch {
+	case result.Err != nil:
+		return "failed"
+	case result.Installed:
+		return "installed"
+	default:
+		return "skipped"
+	}
+}
+
+type installFormatter struct{}
+
+func (installFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	entry := v.(installEntry)
+
+	values = append(values, entry.ID)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+
+	values = append(values, entry.Kind)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgMagentaColor}))
+
+	status := installStatus(entry.InstallResult)
+	values = append(values, status)
+	switch status {
+	case "failed":
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	case "installed":
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor}))
+	default:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+	}
+
+	if entry.Err != nil {
+		values = append(values, entry.Err.Error())
+	} else {
+		values = append(values, "")
+	}
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	return values, options
+}
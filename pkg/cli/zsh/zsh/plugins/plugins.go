@@ -16,6 +16,12 @@ func NewPluginsCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd.AddCommand(newPluginsInstallCommand(f))
 	cmd.AddCommand(newPluginsEnableCommand(f))
 	cmd.AddCommand(newPluginsDisableCommand(f))
+	cmd.AddCommand(newPluginsUpdateCommand(f))
+	cmd.AddCommand(newPluginsUninstallCommand(f))
+	cmd.AddCommand(newPluginsPruneCommand(f))
+	cmd.AddCommand(newPluginsSearchCommand(f))
+	cmd.AddCommand(newPluginsInfoCommand(f))
+	cmd.AddCommand(newPluginsDoctorCommand(f))
 	return cmd
 }
 
@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+var ErrPluginIssues = fmt.Errorf("found plugin issues")
+
+func newPluginsDoctorCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "checks the requirements and load order of the enabled plugins",
+		Long: `checks the enabled plugins for required plugins which are not enabled,
+			required executables which are not on $PATH and after, before and requires declarations contradicting each other
+
+			exits non-zero when issues were found
+		`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := zsh.Load()
+		if err != nil {
+			return err
+		}
+		issues, err := zsh.DiagnosePlugins(cfg, zsh.CurrentFacts())
+		if err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "no issues found")
+			return err
+		}
+
+		var data []interface{}
+		for _, issue := range issues {
+			data = append(data, issue)
+		}
+		sink := out.NewTableSink(cmd.OutOrStdout(), pluginIssueFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Plugin", "Issue", "Detail"})
+		})
+		if err = sink.WriteAndFlush(data); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d", ErrPluginIssues, len(issues))
+	}
+
+	return cmd
+}
+
+type pluginIssueFormatter struct{}
+
+func (pluginIssueFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	issue := v.(zsh.PluginIssue)
+
+	values = append(values, issue.Plugin)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+
+	values = append(values, issue.Kind)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+
+	values = append(values, issue.Detail)
+	options = append(options, out.ColorFormat(tablewriter.Colors{}))
+	return values, options
+}
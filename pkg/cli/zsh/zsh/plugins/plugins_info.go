@@ -0,0 +1,61 @@
+package plugins
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newPluginsInfoCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "info [id]",
+		Short: "shows a plugin or theme of the catalog",
+		Long: `shows the description, repo, install status and enablement of a plugin or theme of the catalog
+			and how to install it
+		`,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		catalog, err := zsh.LoadCatalog()
+		if err != nil {
+			return err
+		}
+		cfg, err := zsh.Load()
+		if err != nil {
+			return err
+		}
+		entries, err := catalog.Find(args[0])
+		if err != nil {
+			return err
+		}
+
+		for n, entry := range entries {
+			if n > 0 {
+				cmd.Println()
+			}
+			status := newCatalogStatus(entry, cfg)
+			cmd.Printf("%-12s %s\n", "Name:", entry.ID)
+			cmd.Printf("%-12s %s\n", "Type:", entry.Type)
+			cmd.Printf("%-12s %s\n", "Kind:", entry.Kind)
+			if entry.Repo != "" {
+				cmd.Printf("%-12s %s\n", "Repo:", zsh.BuildRepositoryURI(entry.Repo, entry.Kind))
+			}
+			if entry.Description != "" {
+				cmd.Printf("%-12s %s\n", "Description:", entry.Description)
+			}
+			cmd.Printf("%-12s %s\n", "Installed:", yesNo(status.Installed))
+			cmd.Printf("%-12s %s\n", "Enabled:", yesNo(status.Enabled))
+			switch {
+			case !status.Installed:
+				cmd.Printf("%-12s %s\n", "Install:", entry.InstallHint())
+			case !status.Enabled && entry.Type == zsh.PluginInstallableKind:
+				cmd.Printf("%-12s %s\n", "Enable:", "dfctl zsh plugins enable "+entry.Installable(cfg).Id())
+			}
+		}
+		return nil
+	}
+
+	return cmd
+}
@@ -21,17 +21,36 @@ func newPluginsInstallCommand(*factory.Factory) (cmd *cobra.Command) {
 				omz     oh-my-zsh bundled plugin
 				gh      github repository
 				git     git repository
+				local   local directory, sourced in place
+				url     http(s) url of a tar or zip archive, verified by --checksum
 		`,
 	}
 
 	nameFlag := cmd.Flags().StringP("name", "n", "", "--name | -n [name of the plugin]")
 	idFlag := cmd.Flags().StringP("id", "i", "", "--id | -i [id of the plugin]")
+	refFlag := cmd.Flags().StringP("ref", "r", "", "--ref | -r [branch, tag or commit to install]")
+	checksumFlag := cmd.Flags().String("checksum", "", "--checksum [sha256:<hex> of the archive of an url plugin]")
+	subdirFlag := cmd.Flags().String("subdir", "", "--subdir [directory of the repo the plugin lives in]")
+	fileFlag := cmd.Flags().String("file", "", "--file [single file of the repo to source]")
+	initFlag := cmd.Flags().String("init", "", "--init [file of the plugin to source instead of its init file]")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		repo := args[0]
-		plugin := zsh.NewPlugin(repo, idFlag, nameFlag)
-		zsh.Install(plugin)
-		return nil
+		plugin, err := zsh.NewPlugin(repo, idFlag, nameFlag)
+		if err != nil {
+			return err
+		}
+		plugin.Ref = *refFlag
+		plugin.Checksum = *checksumFlag
+		plugin.Subdir, plugin.File, plugin.Init = *subdirFlag, *fileFlag, *initFlag
+		result := zsh.Install(plugin)[plugin]
+		if result.Err != nil || !result.Installed {
+			return result.Err
+		}
+
+		return zsh.UpdateLockfile(func(lock *zsh.Lockfile) error {
+			return lock.Record(plugin)
+		})
 	}
 
 	return cmd
@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ahmetb/go-linq"
 	"github.com/olekukonko/tablewriter"
@@ -18,7 +19,7 @@ func newPluginsListCommand(f *factory.Factory) (cmd *cobra.Command) {
 		Use: "list",
 	}
 
-	filters := cmd.PersistentFlags().StringSliceP("filters", "f", []string{"all"}, "--filter [filter1,filter2,..]  (default: all) |  filters: all | enabled | disabled | kind:gh | kind:git | kind:omz |installed | uninstalled ]")
+	filters := cmd.PersistentFlags().StringSliceP("filters", "f", []string{"all"}, "--filter [filter1,filter2,..]  (default: all) |  filters: all | enabled | disabled | kind:gh | kind:git | kind:omz | kind:local | kind:url | installed | uninstalled ]")
 	out := cmd.PersistentFlags().StringP("out", "o", "table", "--out | -o [ list | table ]")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
@@ -61,6 +62,20 @@ func newPluginsListCommand(f *factory.Factory) (cmd *cobra.Command) {
 					}
 					return false
 				})
+			case "kind:local":
+				predicates = append(predicates, func(i zsh.Installable) bool {
+					if it, ok := i.(*zsh.Plugin); ok {
+						return it.Kind == zsh.PLUGIN_LOCAL
+					}
+					return false
+				})
+			case "kind:url":
+				predicates = append(predicates, func(i zsh.Installable) bool {
+					if it, ok := i.(*zsh.Plugin); ok {
+						return it.Kind == zsh.PLUGIN_URL
+					}
+					return false
+				})
 			case "kind:omz":
 				predicates = append(predicates, func(i zsh.Installable) bool {
 					_, ok := i.(*zsh.OMZPlugin)
@@ -108,6 +123,8 @@ type Plugin struct {
 	ID      string
 	Enabled bool
 	Kind    string
+	// Missing are the requirements of the plugin which are not met
+	Missing []string
 }
 
 func (pluginFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
@@ -130,6 +147,10 @@ func (pluginFormatter) Format(v interface{}) (values []string, options []out.For
 		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiYellowColor}))
 	case "omz":
 		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgMagentaColor}))
+	case "local":
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgCyanColor}))
+	case "url":
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgBlueColor}))
 	default:
 		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiRedColor}))
 	}
@@ -144,6 +165,10 @@ func (pluginFormatter) Format(v interface{}) (values []string, options []out.For
 	}
 	values = append(values, fmt.Sprintf("%v", plugin.Enabled))
 
+	// missing requirements
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	values = append(values, strings.Join(plugin.Missing, ", "))
+
 	return values, options
 }
 
@@ -160,6 +185,7 @@ func GetRepoKind(i zsh.Installable) zsh.RepoKind {
 }
 
 func formatOutput(installables []zsh.Installable, outputFormat string, cmd *cobra.Command) {
+	missing := missingRequirements()
 	var data []interface{}
 	linq.
 		From(installables).
@@ -175,6 +201,7 @@ func formatOutput(installables []zsh.Installable, outputFormat string, cmd *cobr
 				ID:      i.Id(),
 				Kind:    kind,
 				Enabled: i.IsEnabled(),
+				Missing: missing[i.Id()],
 			}
 		}).
 		ToSlice(&data)
@@ -182,7 +209,7 @@ func formatOutput(installables []zsh.Installable, outputFormat string, cmd *cobr
 	switch outputFormat {
 	case "table":
 		sink := out.NewTableSink(cmd.OutOrStdout(), pluginFormatter{}, func(t *tablewriter.Table) {
-			t.SetHeader([]string{"Name", "Kind", "Enabled"})
+			t.SetHeader([]string{"Name", "Kind", "Enabled", "Missing"})
 		})
 		if err := sink.WriteAndFlush(data); err != nil {
 			log.Error().Err(err).Msgf("unable to format data %v", data)
@@ -200,3 +227,24 @@ func formatOutput(installables []zsh.Installable, outputFormat string, cmd *cobr
 }
 
 var ErrInvalidOutputFormat = fmt.Errorf("invalid output format")
+
+// missingRequirements returns the unmet requirements of the enabled plugins by their ids
+func missingRequirements() (missing map[string][]string) {
+	missing = map[string][]string{}
+	cfg, err := zsh.Load()
+	if err != nil {
+		log.Error().Err(err).Msgf("unable to load config")
+		return missing
+	}
+	issues, err := zsh.DiagnosePlugins(cfg, zsh.CurrentFacts())
+	if err != nil {
+		log.Error().Err(err).Msgf("unable to check the requirements of the plugins")
+		return missing
+	}
+	for _, issue := range issues {
+		if issue.Kind != zsh.PluginCycle {
+			missing[issue.Plugin] = append(missing[issue.Plugin], issue.Detail)
+		}
+	}
+	return missing
+}
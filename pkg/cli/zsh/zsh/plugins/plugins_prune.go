@@ -0,0 +1,43 @@
+package plugins
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newPluginsPruneCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "prune",
+		Short: "removes installed plugins and themes which are not in the config",
+		Long: `removes the plugins and themes in the plugins and themes dirs which no plugin or theme of the config refers to
+
+			only git clones, archives extracted by dfctl and links to local directories which no longer exist are removed,
+			plugins written by hand into the plugins dir are left alone.
+		`,
+		Args: cobra.NoArgs,
+	}
+
+	dryRun := cmd.Flags().Bool("dry-run", false, "print what would be removed without removing anything")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		orphans, err := zsh.Prune(*dryRun)
+		for _, orphan := range orphans {
+			if *dryRun {
+				cmd.Printf("would remove %s %s\n", orphan.Kind, orphan.Path)
+				continue
+			}
+			cmd.Printf("removed %s %s\n", orphan.Kind, orphan.Path)
+		}
+		if err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			cmd.Println("nothing to prune")
+		}
+		return nil
+	}
+
+	return cmd
+}
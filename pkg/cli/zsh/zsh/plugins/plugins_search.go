@@ -0,0 +1,103 @@
+package plugins
+
+import (
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newPluginsSearchCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "search [term]",
+		Short: "searches the catalog of plugins and themes",
+		Long: `searches the ids, repos and descriptions of the plugins bundled with oh-my-zsh
+			and of the curated community plugins and themes, without access to the network
+
+			a catalog.yaml in the dfctl home extends the curated list.
+		`,
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		catalog, err := zsh.LoadCatalog()
+		if err != nil {
+			return err
+		}
+		cfg, err := zsh.Load()
+		if err != nil {
+			return err
+		}
+
+		entries := catalog.Entries()
+		if len(args) > 0 {
+			entries = catalog.Search(args[0])
+		}
+		var data []interface{}
+		for _, entry := range entries {
+			data = append(data, newCatalogStatus(entry, cfg))
+		}
+
+		sink := out.NewTableSink(cmd.OutOrStdout(), catalogFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Name", "Type", "Kind", "Installed", "Enabled", "Description"})
+		})
+		return sink.WriteAndFlush(data)
+	}
+
+	return cmd
+}
+
+// catalogStatus is a catalog entry together with the state of its plugin or theme on this machine
+type catalogStatus struct {
+	zsh.CatalogEntry
+	Installed bool
+	Enabled   bool
+}
+
+func newCatalogStatus(entry zsh.CatalogEntry, cfg *zsh.ConfigSpec) catalogStatus {
+	i := entry.Installable(cfg)
+	return catalogStatus{CatalogEntry: entry, Installed: i.IsInstalled(), Enabled: i.IsEnabled()}
+}
+
+const maxDescriptionLength = 60
+
+type catalogFormatter struct{}
+
+func (catalogFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	status := v.(catalogStatus)
+
+	values = append(values, status.ID)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+
+	values = append(values, status.Type.String())
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+
+	values = append(values, string(status.Kind))
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgMagentaColor}))
+
+	for _, state := range []bool{status.Installed, status.Enabled} {
+		values = append(values, yesNo(state))
+		if state {
+			options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor}))
+		} else {
+			options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+		}
+	}
+
+	description := []rune(status.Description)
+	if len(description) > maxDescriptionLength {
+		description = append(description[:maxDescriptionLength-1], '…')
+	}
+	values = append(values, string(description))
+	options = append(options, out.ColorFormat(tablewriter.Colors{}))
+	return values, options
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,70 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+func newPluginsUninstallCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:     "uninstall [plugin1 plugin2 plugin3]",
+		Aliases: []string{"rm"},
+		Short:   "uninstalls plugins and themes",
+		Long: `removes the clones of custom plugins and themes and drops them from the config
+
+			plugins bundled with oh-my-zsh can not be uninstalled, disable them instead.
+			uninstalled plugins and themes are removed from the lockfile.
+		`,
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	dryRun := cmd.Flags().Bool("dry-run", false, "print what would be removed without removing anything")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		installables := GetInstallablesByNames(args)
+		found := map[string]bool{}
+		for _, i := range installables {
+			found[i.Id()] = true
+		}
+		for _, id := range args {
+			if !found[id] {
+				return fmt.Errorf("unknown plugin or theme %s", id)
+			}
+		}
+
+		for _, i := range installables {
+			removed, err := zsh.Uninstall(i, *dryRun)
+			if err != nil {
+				return err
+			}
+			printRemoval(cmd, i.Id(), removed, *dryRun)
+		}
+		if *dryRun {
+			return nil
+		}
+
+		return zsh.UpdateLockfile(func(lock *zsh.Lockfile) error {
+			lock.Prune(zsh.ListInstallables())
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+func printRemoval(cmd *cobra.Command, id, removed string, dryRun bool) {
+	switch {
+	case removed == "" && dryRun:
+		cmd.Printf("would uninstall %s, which has no clone\n", id)
+	case removed == "":
+		cmd.Printf("uninstalled %s, which had no clone\n", id)
+	case dryRun:
+		cmd.Printf("would uninstall %s and remove %s\n", id, removed)
+	default:
+		cmd.Printf("uninstalled %s and removed %s\n", id, removed)
+	}
+}
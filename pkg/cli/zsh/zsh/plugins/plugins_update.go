@@ -0,0 +1,158 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+var ErrUpdateFailed = fmt.Errorf("failed to update")
+
+func newPluginsUpdateCommand(*factory.Factory) (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "update [plugin1 plugin2 plugin3]",
+		Short: "updates installed plugins and themes",
+		Long: `fetches and fast-forwards the installed git and github plugins and themes
+
+			plugins and themes with uncommitted changes or untracked files are skipped,
+			unless --force is given, which discards those changes.
+			plugins and themes pinned to a tag or commit are skipped as well.
+			the new commits are recorded in the lockfile.
+		`,
+	}
+
+	all := cmd.Flags().Bool("all", false, "update all installed plugins and themes")
+	force := cmd.Flags().Bool("force", false, "update checkouts with uncommitted changes, discarding them")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		updatables, err := getUpdatables(args, *all)
+		if err != nil {
+			return err
+		}
+
+		var data []interface{}
+		var updated []zsh.Updatable
+		failed := 0
+		for _, u := range updatables {
+			result := u.Update(*force)
+			if result.Err != nil && !result.Skipped {
+				failed++
+			}
+			if result.Updated() {
+				updated = append(updated, u)
+			}
+			data = append(data, updateEntry{ID: u.Id(), Kind: u.GetKind().String(), UpdateResult: result})
+		}
+
+		sink := out.NewTableSink(cmd.OutOrStdout(), updateFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Name", "Kind", "Update"})
+		})
+		if err = sink.WriteAndFlush(data); err != nil {
+			return err
+		}
+		if err = zsh.UpdateLockfile(func(lock *zsh.Lockfile) error {
+			for _, u := range updated {
+				if err := lock.Record(u); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%w %d of %d", ErrUpdateFailed, failed, len(updatables))
+		}
+		return nil
+	}
+
+	return cmd
+}
+
+func getUpdatables(ids []string, all bool) (updatables []zsh.Updatable, err error) {
+	switch {
+	case all && len(ids) > 0:
+		return nil, fmt.Errorf("either pass plugin ids or --all")
+	case all:
+		for _, i := range zsh.ListInstallables(zsh.InstalledFilterFn(true)) {
+			if u, ok := i.(zsh.Updatable); ok && !isBundled(u) {
+				updatables = append(updatables, u)
+			}
+		}
+		return updatables, nil
+	case len(ids) == 0:
+		return nil, fmt.Errorf("pass the ids of the plugins to update or --all")
+	}
+
+	found := map[string]bool{}
+	for _, i := range GetInstallablesByNames(ids) {
+		found[i.Id()] = true
+		u, ok := i.(zsh.Updatable)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is bundled with oh-my-zsh", zsh.ErrNotUpdatable, i.Id())
+		}
+		if !i.IsInstalled() {
+			return nil, fmt.Errorf("%s is not installed", i.Id())
+		}
+		updatables = append(updatables, u)
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("unknown plugin or theme %s", id)
+		}
+	}
+	return updatables, nil
+}
+
+func isBundled(u zsh.Updatable) bool {
+	switch it := u.(type) {
+	case *zsh.Plugin:
+		return it.Kind == zsh.PLUGIN_OMZ
+	case *zsh.Theme:
+		return it.Kind == zsh.PLUGIN_OMZ
+	}
+	return false
+}
+
+type updateEntry struct {
+	zsh.UpdateResult
+	ID   string
+	Kind string
+}
+
+type updateFormatter struct{}
+
+func (updateFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	entry := v.(updateEntry)
+
+	values = append(values, entry.ID)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+
+	values = append(values, entry.Kind)
+	options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgMagentaColor}))
+
+	switch {
+	case entry.Skipped && errors.Is(entry.Err, zsh.ErrDirtyWorktree):
+		values = append(values, "skipped: "+entry.Err.Error()+", use --force to discard them")
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+	case entry.Skipped:
+		values = append(values, "skipped: "+entry.Err.Error())
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+	case entry.Err != nil:
+		values = append(values, entry.Err.Error())
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	case entry.Updated():
+		values = append(values, entry.Range())
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor}))
+	default:
+		values = append(values, "up to date")
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+	}
+	return values, options
+}
@@ -0,0 +1,78 @@
+package zsh
+
+import (
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/out"
+	"github.com/alex-held/dfctl/pkg/zsh"
+)
+
+// slowEntry is the load time from which on entries are highlighted
+const slowEntry = 100 * time.Millisecond
+
+func newProfileCommand(f *factory.Factory) (cmd *cobra.Command) {
+	cmd = f.NewCommand("profile",
+		factory.WithHelp("measures how long the sections and plugins of the .zshrc take to load", "starts an interactive zsh with an instrumented version of the generated .zshrc and prints the load times of its sections, followed by the plugins, themes and files sourced while loading it"),
+	)
+	template := cmd.Flags().String("template", "", "template file applied on top of the built-in template and the overrides")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		var opts []zsh.RenderOption
+		if *template != "" {
+			opts = append(opts, zsh.WithTemplate(*template))
+		}
+
+		profile, err := zsh.RunProfile(opts...)
+		if err != nil {
+			return err
+		}
+
+		var data []interface{}
+		for _, entry := range profile.Entries {
+			data = append(data, entry)
+		}
+		data = append(data, zsh.ProfileEntry{Kind: "total", Duration: profile.Total})
+
+		sink := out.NewTableSink(cmd.OutOrStdout(), profileFormatter{}, func(t *tablewriter.Table) {
+			t.SetHeader([]string{"Kind", "Name", "Time"})
+		})
+		return sink.WriteAndFlush(data)
+	}
+	return cmd
+}
+
+type profileFormatter struct{}
+
+func (profileFormatter) Format(v interface{}) (values []string, options []out.FormatOption) {
+	entry := v.(zsh.ProfileEntry)
+
+	values = append(values, entry.Kind)
+	switch entry.Kind {
+	case zsh.ProfileSection:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgYellowColor}))
+	case zsh.ProfilePlugin:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgGreenColor}))
+	case zsh.ProfileTheme:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgCyanColor}))
+	case zsh.ProfileSource:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgHiWhiteColor}))
+	default:
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.Bold}))
+	}
+
+	values = append(values, strings.Repeat("  ", entry.Depth)+entry.Name)
+	options = append(options, out.ColorFormat(tablewriter.Colors{}))
+
+	values = append(values, entry.Duration.Round(10*time.Microsecond).String())
+	if entry.Duration >= slowEntry && entry.Kind != "total" {
+		options = append(options, out.ColorFormat(tablewriter.Colors{tablewriter.FgRedColor}))
+	} else {
+		options = append(options, out.ColorFormat(tablewriter.Colors{}))
+	}
+	return values, options
+}
@@ -1,7 +1,9 @@
 package zsh
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -9,20 +11,40 @@ import (
 	"github.com/alex-held/dfctl/pkg/zsh"
 )
 
-func newSourceCommand(f *factory.Factory) (cmd *cobra.Command) {
+// NewSourceCommand creates the source command, which is available as `dfctl source` and `dfctl zsh source`
+func NewSourceCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd = f.NewCommand("source",
-		factory.WithHelp("outputs valid a generated .zshrc based on your configuration", ""),
+		factory.WithHelp("outputs valid a generated .zshrc based on your configuration", "renders the .zshrc from the built-in template, the overrides in "+zsh.TemplatesDir()+" and the optional --template file.\nwith --shell bash or fish, the exports, paths, sources, user configs and aliases are rendered for that shell instead"),
 		factory.WithAnnotationKeys("IsCore"),
 	)
+	shell := cmd.Flags().StringP("shell", "s", "zsh", fmt.Sprintf("--shell | -s [ %s ]", strings.Join(zsh.Shells, " | ")))
+	template := cmd.Flags().String("template", "", "template file applied on top of the built-in template and the overrides, e.g. to test an override")
+	cached := cmd.Flags().Bool("cached", false, "print the path of the cached and compiled output instead, e.g. source \"$(dfctl zsh source --cached)\"")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		return runSourceCommand(cmd, args)
+		opts := []zsh.RenderOption{zsh.WithShell(*shell)}
+		if *template != "" {
+			opts = append(opts, zsh.WithTemplate(*template))
+		}
+		if *cached {
+			return runCachedSourceCommand(cmd, opts...)
+		}
+		return runSourceCommand(cmd, args, opts...)
 	}
 	return cmd
 }
 
-func runSourceCommand(cmd *cobra.Command, args []string) (err error) {
-	source, err := zsh.Source()
+func runCachedSourceCommand(cmd *cobra.Command, opts ...zsh.RenderOption) (err error) {
+	path, err := zsh.Cached(opts...)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), path)
+	return err
+}
+
+func runSourceCommand(cmd *cobra.Command, args []string, opts ...zsh.RenderOption) (err error) {
+	source, err := zsh.Source(opts...)
 	if err != nil {
 		return err
 	}
@@ -10,7 +10,7 @@ import (
 func NewZshCommand(f *factory.Factory) (cmd *cobra.Command) {
 	cmd = f.NewCommand("zsh",
 		factory.WithHelp("interacts with the zsh configuration", ""),
-		factory.WithSubcommands(newSourceCommand),
+		factory.WithSubcommands(NewSourceCommand, newApplyCommand, newProfileCommand),
 		factory.WithGroupedSubcommands("plugins", plugins.NewPluginsCommand, newInstallCommand),
 	)
 	return cmd
@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (o Op) Prefix() string {
+	switch o {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Stats summarizes a line diff by the number of inserted and deleted lines.
+type Stats struct {
+	Insertions int
+	Deletions  int
+}
+
+func (s Stats) HasChanges() bool { return s.Insertions > 0 || s.Deletions > 0 }
+
+func (s Stats) String() string {
+	return fmt.Sprintf("+%d -%d", s.Insertions, s.Deletions)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines computes the line based diff between a and b using their longest common subsequence.
+func Lines(a, b string) (lines []Line) {
+	as, bs := splitLines(a), splitLines(b)
+
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			switch {
+			case as[i] == bs[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		switch {
+		case as[i] == bs[j]:
+			lines = append(lines, Line{Equal, as[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, as[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, bs[j]})
+			j++
+		}
+	}
+	for ; i < len(as); i++ {
+		lines = append(lines, Line{Delete, as[i]})
+	}
+	for ; j < len(bs); j++ {
+		lines = append(lines, Line{Insert, bs[j]})
+	}
+	return lines
+}
+
+func Summarize(a, b string) (stats Stats) {
+	for _, line := range Lines(a, b) {
+		switch line.Op {
+		case Insert:
+			stats.Insertions++
+		case Delete:
+			stats.Deletions++
+		}
+	}
+	return stats
+}
+
+// Unified renders the diff between a and b in unified format with the given amount of context lines.
+// An empty string is returned when a and b are equal.
+func Unified(aName, bName, a, b string, context int) string {
+	lines := Lines(a, b)
+
+	var changed []int
+	for i, line := range lines {
+		if line.Op != Equal {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", aName, bName)
+
+	for h := 0; h < len(changed); {
+		start := changed[h] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changed[h] + context + 1
+
+		// merge changes whose context overlaps into the same hunk
+		for h++; h < len(changed) && changed[h]-context <= end; h++ {
+			end = changed[h] + context + 1
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		aStart, bStart := 1, 1
+		for _, line := range lines[:start] {
+			if line.Op != Insert {
+				aStart++
+			}
+			if line.Op != Delete {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, line := range lines[start:end] {
+			if line.Op != Insert {
+				aLen++
+			}
+			if line.Op != Delete {
+				bLen++
+			}
+		}
+
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, line := range lines[start:end] {
+			sb.WriteString(line.Op.Prefix() + line.Text + "\n")
+		}
+	}
+
+	return sb.String()
+}
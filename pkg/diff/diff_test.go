@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	tt := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name: "insert",
+			a:    "a\nc\n",
+			b:    "a\nb\nc\n",
+			want: []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}},
+		},
+		{
+			name: "delete",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			want: []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}},
+		},
+		{
+			name: "replace",
+			a:    "theme: simple\n",
+			b:    "theme: agnoster\n",
+			want: []Line{{Delete, "theme: simple"}, {Insert, "theme: agnoster"}},
+		},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Lines(tt.a, tt.b))
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := Summarize("a\nb\nc\n", "a\nc\nd\ne\n")
+	assert.Equal(t, Stats{Insertions: 2, Deletions: 1}, stats)
+	assert.Equal(t, "+2 -1", stats.String())
+}
+
+func TestUnified(t *testing.T) {
+	assert.Empty(t, Unified("a", "b", "x\n", "x\n", 3))
+
+	got := Unified("old", "new", "a\nb\nc\nd\ne\nf\ng\n", "a\nb\nc\nD\ne\nf\ng\n", 1)
+	want := `--- old
++++ new
+@@ -3,3 +3,3 @@
+ c
+-d
++D
+ e
+`
+	assert.Equal(t, want, got)
+}
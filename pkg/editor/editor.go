@@ -0,0 +1,68 @@
+package editor
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/cli/safeexec"
+	"github.com/google/shlex"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+	"github.com/alex-held/dfctl-kit/pkg/iostreams"
+)
+
+// EnvVars are consulted in order to find the editor the user prefers.
+var EnvVars = []string{"VISUAL", "EDITOR"}
+
+// Fallbacks are tried in order when none of the EnvVars is set.
+var Fallbacks = []string{"vim", "vi", "nano"}
+
+var ErrNoEditor = fmt.Errorf("no editor found; set $VISUAL or $EDITOR")
+
+var lookPath = safeexec.LookPath
+
+// Resolve returns the command line of the editor to use.
+// $VISUAL and $EDITOR may contain arguments and are split like shell words,
+// e.g. `code --wait` or `"/Applications/Sublime Text.app/Contents/SharedSupport/bin/subl" -w`.
+func Resolve() (args []string, err error) {
+	vars := env.GetVars()
+	for _, name := range EnvVars {
+		if value := strings.TrimSpace(vars.Get(name)); value != "" {
+			if args, err = shlex.Split(value); err != nil || len(args) == 0 {
+				return nil, fmt.Errorf("invalid $%s %q: %v", name, value, err)
+			}
+			return args, nil
+		}
+	}
+
+	fallbacks := Fallbacks
+	if runtime.GOOS == "windows" {
+		fallbacks = []string{"notepad"}
+	}
+	for _, fallback := range fallbacks {
+		if _, err = lookPath(fallback); err == nil {
+			return []string{fallback}, nil
+		}
+	}
+	return nil, ErrNoEditor
+}
+
+// Edit opens path in the resolved editor and waits until the editor exits.
+func Edit(streams *iostreams.IOStreams, path string) (err error) {
+	args, err := Resolve()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = streams.In
+	cmd.Stdout = streams.Out
+	cmd.Stderr = streams.Err
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s exited with error: %w", args[0], err)
+	}
+	return nil
+}
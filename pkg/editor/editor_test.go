@@ -0,0 +1,68 @@
+package editor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func TestResolve(t *testing.T) {
+	tt := []struct {
+		name      string
+		vars      env.Vars
+		available []string
+		want      []string
+		err       error
+	}{
+		{
+			name: "prefers $VISUAL over $EDITOR",
+			vars: env.Vars{"VISUAL": "code --wait", "EDITOR": "nano"},
+			want: []string{"code", "--wait"},
+		},
+		{
+			name: "splits quoted paths like shell words",
+			vars: env.Vars{"VISUAL": `"/Applications/Sublime Text.app/Contents/SharedSupport/bin/subl" -w`},
+			want: []string{"/Applications/Sublime Text.app/Contents/SharedSupport/bin/subl", "-w"},
+		},
+		{
+			name: "uses $EDITOR when $VISUAL is empty",
+			vars: env.Vars{"VISUAL": "", "EDITOR": "nano"},
+			want: []string{"nano"},
+		},
+		{
+			name:      "falls back to the first available editor",
+			vars:      env.Vars{"VISUAL": "", "EDITOR": ""},
+			available: []string{"vi", "nano"},
+			want:      []string{"vi"},
+		},
+		{
+			name: "fails without any editor",
+			vars: env.Vars{"VISUAL": "", "EDITOR": ""},
+			err:  ErrNoEditor,
+		},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			env.Overrides.Vars = tt.vars
+			defer env.ClearOverrides()
+			original := lookPath
+			t.Cleanup(func() { lookPath = original })
+
+			lookPath = func(file string) (string, error) {
+				for _, available := range tt.available {
+					if available == file {
+						return "/usr/bin/" + file, nil
+					}
+				}
+				return "", fmt.Errorf("%s not found", file)
+			}
+
+			got, err := Resolve()
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
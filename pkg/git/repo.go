@@ -8,8 +8,6 @@ import (
 	"net/url"
 	"os"
 	"strings"
-
-	"github.com/go-git/go-git/v5"
 )
 
 type RepositoryKind int
@@ -78,13 +76,6 @@ func (r *repository) GetName() string {
 	return r.Name
 }
 
-func (r *repository) Clone(path string) (err error) {
-	_, err = git.PlainClone(path, false, &git.CloneOptions{
-		URL: r.URI(),
-	})
-	return err
-}
-
 func (r *repository) APIURI(paths ...string) string {
 	path := ""
 	if len(paths) > 0 {
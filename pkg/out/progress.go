@@ -0,0 +1,58 @@
+package out
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// IsTerminal returns whether w writes to a terminal
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// Progress shows one status line per item and redraws them in place whenever one changes.
+// Nothing is shown unless Progress writes to a terminal, so that logs and pipes only get the final output.
+type Progress struct {
+	w     io.Writer
+	live  bool
+	keys  []string
+	lines map[string]string
+	drawn int
+	mu    sync.Mutex
+}
+
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{w: w, live: IsTerminal(w), lines: map[string]string{}}
+}
+
+// Set updates the status line of the item key; new items are added below the existing ones
+func (p *Progress) Set(key, line string) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.lines[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.lines[key] = line
+	if !p.live {
+		return nil
+	}
+
+	if p.drawn > 0 {
+		if _, err = fmt.Fprintf(p.w, "\x1b[%dA", p.drawn); err != nil {
+			return err
+		}
+	}
+	for _, k := range p.keys {
+		if _, err = fmt.Fprintf(p.w, "\x1b[2K%s\n", p.lines[k]); err != nil {
+			return err
+		}
+	}
+	p.drawn = len(p.keys)
+	return nil
+}
@@ -0,0 +1,167 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+const (
+	managedBlockBegin = "# >>> dfctl managed block >>>"
+	managedBlockEnd   = "# <<< dfctl managed block <<<"
+
+	zshrcBackupLayout = "20060102T150405"
+)
+
+var ErrOutOfDate = fmt.Errorf("zshrc is out of date; run `dfctl zsh apply`")
+var ErrMalformedBlock = fmt.Errorf("managed block is missing its end marker %q", managedBlockEnd)
+
+// ZshrcFile returns the path of the user's .zshrc, which is located in $ZDOTDIR or else $HOME
+func ZshrcFile() string {
+	vars := env.GetVars()
+	if dir := vars.Get("ZDOTDIR"); dir != "" {
+		return filepath.Join(dir, ".zshrc")
+	}
+	return filepath.Join(vars.Get("HOME"), ".zshrc")
+}
+
+// legacyRenderedFile is the path earlier versions of Apply wrote the rendered zshrc to, before the managed block sourced the cache
+func legacyRenderedFile() string {
+	return filepath.Join(CacheDir(), "zshrc.zsh")
+}
+
+// ApplyResult describes the changes made by Apply
+type ApplyResult struct {
+	// Cached is the cached startup file the managed block sources; it is only set when Apply wrote the zshrc
+	Cached string
+	Zshrc  string
+	// Backup is the copy of the Zshrc taken before updating it, if it got updated
+	Backup string
+
+	ZshrcChanged bool
+}
+
+// UpToDate reports whether the zshrc did not need to change
+func (r *ApplyResult) UpToDate() bool {
+	return !r.ZshrcChanged
+}
+
+// Apply inserts or updates the managed block in ZshrcFile, which sources the cached startup file of `dfctl zsh source --cached`.
+// Since the cache gets invalidated whenever the config, the templates or the installed plugins change, the zshrc only
+// needs to be applied once. Without check, the cache gets rendered right away, so that the next shell starts fast.
+// Content outside the managed block is preserved and a backup of the zshrc is taken before it gets updated.
+// With check, nothing gets written and ErrOutOfDate is returned if the zshrc would change.
+func Apply(check bool, opts ...RenderOption) (result *ApplyResult, err error) {
+	fs := factory.Default.Fs
+	result = &ApplyResult{Zshrc: ZshrcFile()}
+
+	if result.Zshrc, err = resolveSymlink(fs, result.Zshrc); err != nil {
+		return result, err
+	}
+	zshrc, err := readFileIfExists(fs, result.Zshrc)
+	if err != nil {
+		return result, err
+	}
+	updated, err := upsertManagedBlock(string(zshrc), managedBlock())
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", result.Zshrc, err)
+	}
+	result.ZshrcChanged = updated != string(zshrc)
+
+	if check {
+		if !result.UpToDate() {
+			return result, ErrOutOfDate
+		}
+		return result, nil
+	}
+
+	if result.Cached, err = Cached(opts...); err != nil {
+		return result, err
+	}
+	for _, legacy := range []string{legacyRenderedFile(), legacyRenderedFile() + ".zwc"} {
+		if err = fs.Remove(legacy); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+	}
+
+	if result.ZshrcChanged {
+		perm := configFilePerm
+		if info, err := fs.Stat(result.Zshrc); err == nil {
+			perm = info.Mode().Perm()
+			result.Backup = result.Zshrc + ".dfctl-" + now().Format(zshrcBackupLayout) + ".bak"
+			if err = afero.WriteFile(fs, result.Backup, zshrc, perm); err != nil {
+				return result, err
+			}
+		}
+		if err = writeFileAtomic(fs, result.Zshrc, []byte(updated), perm); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func managedBlock() string {
+	return strings.Join([]string{
+		managedBlockBegin,
+		"# managed by `dfctl zsh apply`; changes inside this block get overwritten",
+		`if (( $+commands[dfctl] )); then`,
+		`  _dfctl_zshrc="$(dfctl zsh source --cached)" && source "$_dfctl_zshrc"`,
+		`  unset _dfctl_zshrc`,
+		`fi`,
+		managedBlockEnd,
+	}, "\n") + "\n"
+}
+
+// upsertManagedBlock replaces the managed block of content by block or appends block, if content has none
+func upsertManagedBlock(content, block string) (updated string, err error) {
+	begin := strings.Index(content, managedBlockBegin+"\n")
+	if begin < 0 {
+		if content != "" {
+			content = strings.TrimSuffix(content, "\n") + "\n\n"
+		}
+		return content + block, nil
+	}
+
+	end := strings.Index(content[begin:], managedBlockEnd)
+	if end < 0 {
+		return content, ErrMalformedBlock
+	}
+	end += begin + len(managedBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:begin] + block + content[end:], nil
+}
+
+func readFileIfExists(fs afero.Fs, path string) (content []byte, err error) {
+	content, err = afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+// resolveSymlink returns the target of path if it is a symlink, e.g. into a dotfiles repository,
+// so that writing the file does not replace the link
+func resolveSymlink(fs afero.Fs, path string) (string, error) {
+	reader, ok := fs.(afero.LinkReader)
+	if !ok {
+		return path, nil
+	}
+	target, err := reader.ReadlinkIfPossible(path)
+	if err != nil {
+		// path does not exist or is no symlink
+		return path, nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return target, nil
+}
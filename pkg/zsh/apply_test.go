@@ -0,0 +1,110 @@
+package zsh
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestUpsertManagedBlock(t *testing.T) {
+	block := managedBlock()
+
+	tt := []struct {
+		name    string
+		content string
+		want    string
+		wantErr error
+	}{
+		{name: "empty", content: "", want: block},
+		{name: "append", content: "export A=1", want: "export A=1\n\n" + block},
+		{
+			name:    "replace",
+			content: "export A=1\n" + managedBlockBegin + "\nsource old\n" + managedBlockEnd + "\nexport B=2\n",
+			want:    "export A=1\n" + block + "export B=2\n",
+		},
+		{
+			name:    "missing end marker",
+			content: managedBlockBegin + "\nsource old\n",
+			wantErr: ErrMalformedBlock,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := upsertManagedBlock(tc.content, block)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			again, err := upsertManagedBlock(got, block)
+			assert.NoError(t, err)
+			assert.Equal(t, got, again, "upserting is idempotent")
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	withClock(t)
+	env.Overrides.Vars = env.Vars{"HOME": "/home/dfctl", "ZDOTDIR": ""}
+	fs := factory.Default.Fs
+
+	assert.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+	assert.NoError(t, afero.WriteFile(fs, "/home/dfctl/.zshrc", []byte("# my zshrc\n"), 0600))
+
+	_, err := Apply(true)
+	assert.ErrorIs(t, err, ErrOutOfDate)
+
+	assert.NoError(t, afero.WriteFile(fs, legacyRenderedFile(), []byte("# rendered by an earlier dfctl\n"), 0644))
+	result, err := Apply(false)
+	assert.NoError(t, err)
+	assert.True(t, result.ZshrcChanged)
+	assert.Equal(t, "/home/dfctl/.zshrc", result.Zshrc)
+
+	zshrc, err := afero.ReadFile(fs, result.Zshrc)
+	assert.NoError(t, err)
+	assert.Equal(t, "# my zshrc\n\n"+managedBlock(), string(zshrc))
+
+	backup, err := afero.ReadFile(fs, result.Backup)
+	assert.NoError(t, err)
+	assert.Equal(t, "# my zshrc\n", string(backup))
+
+	info, err := fs.Stat(result.Zshrc)
+	assert.NoError(t, err)
+	assert.Equal(t, 0600, int(info.Mode().Perm()), "the permissions of the zshrc are kept")
+
+	cachedPath, err := Cached()
+	assert.NoError(t, err)
+	assert.Equal(t, cachedPath, result.Cached, "apply renders the cache the managed block sources")
+	rendered, err := Source()
+	assert.NoError(t, err)
+	cached, err := afero.ReadFile(fs, result.Cached)
+	assert.NoError(t, err)
+	assert.Equal(t, rendered, string(cached))
+	exists, err := afero.Exists(fs, legacyRenderedFile())
+	assert.NoError(t, err)
+	assert.False(t, exists, "the file rendered by earlier versions gets removed")
+
+	result, err = Apply(true)
+	assert.NoError(t, err)
+	assert.True(t, result.UpToDate())
+
+	assert.NoError(t, Update(func(cfg *ConfigSpec) error {
+		cfg.Theme = "agnoster"
+		return nil
+	}))
+	result, err = Apply(true)
+	assert.NoError(t, err, "the cache gets invalidated by config changes, so the zshrc stays up to date")
+	assert.False(t, result.ZshrcChanged)
+	updated, err := Cached()
+	assert.NoError(t, err)
+	assert.NotEqual(t, cachedPath, updated)
+}
@@ -0,0 +1,162 @@
+package zsh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cli/safeexec"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/globals"
+)
+
+var lookPath = safeexec.LookPath
+
+// CacheDir returns the directory containing the cached startup files
+func CacheDir() string {
+	return filepath.Join(env.Home(), "cache")
+}
+
+// Cached returns the path of the cached startup file rendered from the current config.
+//
+// The cache is keyed by a hash of the dfctl version, the config file, the template files, the facts of the machine
+// and the init files of the installed plugins and themes, so that it gets invalidated automatically when any of them
+// changes. Values interpolating `${{ env.NAME }}` facts are not part of the key. On a cache miss, the startup file gets
+// rendered, zsh startup files get compiled using zcompile when zsh is available, and outdated cache entries get removed.
+func Cached(opts ...RenderOption) (path string, err error) {
+	o := newRenderOptions(opts...)
+	if _, err = RendererFor(o.Shell); err != nil {
+		return "", err
+	}
+	shell := strings.ToLower(o.Shell)
+	if shell == "" {
+		shell = "zsh"
+	}
+
+	key, err := cacheKey(shell, o)
+	if err != nil {
+		return "", err
+	}
+	fs := factory.Default.Fs
+	path = filepath.Join(CacheDir(), fmt.Sprintf("%src-%s.%s", shell, key, shell))
+	if _, err = fs.Stat(path); err == nil {
+		return path, nil
+	}
+
+	rendered, err := Source(opts...)
+	if err != nil {
+		return "", err
+	}
+	if err = fs.MkdirAll(CacheDir(), configDirPerm); err != nil {
+		return "", err
+	}
+	if err = writeFileAtomic(fs, path, []byte(rendered), configFilePerm); err != nil {
+		return "", err
+	}
+	pruneCache(fs, shell, path)
+
+	if shell == "zsh" {
+		compile(fs, path)
+	}
+	return path, nil
+}
+
+func cacheKey(shell string, o *RenderOptions) (key string, err error) {
+	fs := factory.Default.Fs
+	h := sha256.New()
+	facts := CurrentFacts()
+	writeKeyParts(h, globals.Version, shell, facts.OS, facts.Arch, facts.Hostname, facts.User, facts.Home)
+
+	overrides, err := afero.Glob(fs, filepath.Join(TemplatesDir(), "*.tmpl"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(overrides)
+
+	for _, path := range append([]string{ConfigFile()}, append(overrides, o.Templates...)...) {
+		content, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return "", err
+		}
+		writeKeyParts(h, path, string(content))
+	}
+
+	installed, err := installedKeyParts()
+	if err != nil {
+		return "", err
+	}
+	writeKeyParts(h, installed...)
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// installedKeyParts returns the plugin and theme files the config resolves to, which change when plugins and themes
+// get installed, updated or removed without changing the config
+func installedKeyParts() (parts []string, err error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	data := &renderData{}
+	if err = data.resolvePlugins(cfg); err != nil {
+		return nil, err
+	}
+	parts = append(parts, data.ThemeDir, data.ThemeFile)
+	parts = append(parts, data.PluginDirs...)
+	parts = append(parts, data.PluginInits...)
+	for _, source := range data.PluginSources {
+		parts = append(parts, source.Dir, source.Init)
+	}
+	parts = append(parts, data.LazyPluginDirs...)
+	parts = append(parts, data.DeferredInits...)
+	for _, plugin := range data.CommandPlugins {
+		parts = append(parts, plugin.Init)
+	}
+	return parts, nil
+}
+
+// writeKeyParts writes the length prefixed parts to h, so that the boundaries of the parts are part of the hash
+func writeKeyParts(h hash.Hash, parts ...string) {
+	for _, part := range parts {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+}
+
+func pruneCache(fs afero.Fs, shell, current string) {
+	entries, err := afero.Glob(fs, filepath.Join(CacheDir(), shell+"rc-*"))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry == current || entry == current+".zwc" {
+			continue
+		}
+		if err = fs.Remove(entry); err != nil {
+			log.Debug().Err(err).Msgf("unable to remove outdated cache entry %s", entry)
+		}
+	}
+}
+
+// compile compiles the zsh script at path into path.zwc, which zsh prefers when sourcing path
+func compile(fs afero.Fs, path string) {
+	if _, ok := fs.(*afero.OsFs); !ok {
+		return
+	}
+	zsh, err := lookPath("zsh")
+	if err != nil {
+		log.Debug().Msgf("skipping zcompile of %s; zsh not found", path)
+		return
+	}
+	if out, err := exec.Command(zsh, "-fc", `zcompile -- "$1"`, "zsh", path).CombinedOutput(); err != nil {
+		log.Warn().Err(err).Msgf("zcompile of %s failed: %s", path, out)
+	}
+}
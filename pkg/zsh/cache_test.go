@@ -0,0 +1,88 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+	"github.com/alex-held/dfctl/pkg/globals"
+)
+
+func TestCached(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	fs := factory.Default.Fs
+	assert.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	path, err := Cached()
+	assert.NoError(t, err)
+	assert.Equal(t, CacheDir(), filepath.Dir(path))
+
+	rendered, err := Source()
+	assert.NoError(t, err)
+	cached, err := afero.ReadFile(fs, path)
+	assert.NoError(t, err)
+	assert.Equal(t, rendered, string(cached))
+
+	assert.NoError(t, afero.WriteFile(fs, path, []byte("# from cache"), 0644))
+	hit, err := Cached()
+	assert.NoError(t, err)
+	assert.Equal(t, path, hit)
+	cached, err = afero.ReadFile(fs, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# from cache", string(cached), "cache hits are not rendered again")
+
+	bash, err := Cached(WithShell("bash"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, path, bash)
+
+	assert.NoError(t, Update(func(cfg *ConfigSpec) error {
+		cfg.Theme = "agnoster"
+		return nil
+	}))
+	changed, err := Cached()
+	assert.NoError(t, err)
+	assert.NotEqual(t, path, changed, "changing the config invalidates the cache")
+
+	version := globals.Version
+	globals.Version = "v999.0.0"
+	defer func() { globals.Version = version }()
+	upgraded, err := Cached()
+	assert.NoError(t, err)
+	assert.NotEqual(t, changed, upgraded, "changing the dfctl version invalidates the cache")
+
+	for _, outdated := range []string{path, changed} {
+		_, err = fs.Stat(outdated)
+		assert.Error(t, err, "outdated cache entries are removed")
+	}
+	_, err = fs.Stat(bash)
+	assert.NoError(t, err, "cache entries of other shells are kept")
+}
+
+func TestCached_InstalledPlugins(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	fs := factory.Default.Fs
+	assert.NoError(t, Save(&ConfigSpec{Framework: FRAMEWORK_NONE, Plugins: PluginsSpec{Custom: PluginsList{
+		{ID: "zsh-autosuggestions", Name: "zsh-autosuggestions", Repo: "zsh-users/zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true},
+	}}}))
+	init := filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh")
+
+	path, err := Cached()
+	assert.NoError(t, err)
+	cached, err := afero.ReadFile(fs, path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(cached), init)
+
+	assert.NoError(t, afero.WriteFile(fs, init, nil, 0644))
+	installed, err := Cached()
+	assert.NoError(t, err)
+	assert.NotEqual(t, path, installed, "installing a plugin invalidates the cache")
+	cached, err = afero.ReadFile(fs, installed)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cached), fmt.Sprintf("source %q", init))
+}
@@ -0,0 +1,217 @@
+package zsh
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+//go:embed catalog.yaml
+var bundledCatalog []byte
+
+var ErrNotInCatalog = fmt.Errorf("not in the catalog")
+
+// CatalogEntry describes a plugin or theme which can be installed
+type CatalogEntry struct {
+	ID          string          `yaml:"id"`
+	Repo        string          `yaml:"repo,omitempty"`
+	Kind        RepoKind        `yaml:"kind"`
+	Description string          `yaml:"description,omitempty"`
+	Type        InstallableKind `yaml:"-"`
+}
+
+// Catalog indexes the plugins bundled with oh-my-zsh and a curated list of community plugins and themes
+type Catalog struct {
+	Plugins []CatalogEntry `yaml:"plugins,omitempty"`
+	Themes  []CatalogEntry `yaml:"themes,omitempty"`
+}
+
+// CatalogFile returns the path of the catalog extending the bundled one
+func CatalogFile() string {
+	return filepath.Join(env.Home(), "catalog.yaml")
+}
+
+// LoadCatalog indexes the plugins of the local oh-my-zsh, taking their descriptions from their READMEs,
+// followed by the bundled curated list. The entries of the CatalogFile replace bundled ones with the same id and kind.
+func LoadCatalog() (catalog *Catalog, err error) {
+	catalog = &Catalog{}
+	if err = yaml.Unmarshal(bundledCatalog, catalog); err != nil {
+		return nil, fmt.Errorf("invalid bundled catalog: %w", err)
+	}
+
+	data, err := readFileIfExists(factory.Default.Fs, CatalogFile())
+	if err != nil {
+		return nil, err
+	}
+	custom := &Catalog{}
+	if err = yaml.Unmarshal(data, custom); err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", CatalogFile(), err)
+	}
+	catalog.Plugins = mergeCatalogEntries(catalog.Plugins, custom.Plugins)
+	catalog.Themes = mergeCatalogEntries(catalog.Themes, custom.Themes)
+
+	omz, err := omzCatalogEntries()
+	if err != nil {
+		return nil, err
+	}
+	catalog.Plugins = append(omz, catalog.Plugins...)
+
+	for i := range catalog.Plugins {
+		catalog.Plugins[i].Type = PluginInstallableKind
+	}
+	for i := range catalog.Themes {
+		catalog.Themes[i].Type = ThemeInstallableKind
+	}
+	return catalog, nil
+}
+
+// mergeCatalogEntries replaces the entries with the ids of overrides and appends the remaining overrides
+func mergeCatalogEntries(entries, overrides []CatalogEntry) []CatalogEntry {
+	for _, override := range overrides {
+		replaced := false
+		for i, entry := range entries {
+			if entry.ID == override.ID && entry.Kind == override.Kind {
+				entries[i], replaced = override, true
+			}
+		}
+		if !replaced {
+			entries = append(entries, override)
+		}
+	}
+	return entries
+}
+
+// omzCatalogEntries indexes the plugins of the local oh-my-zsh; without oh-my-zsh there are none
+func omzCatalogEntries() (entries []CatalogEntry, err error) {
+	plugins, err := GetOMZPlugins()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, plugin := range plugins {
+		readme, _ := afero.ReadFile(factory.Default.Fs, filepath.Join(plugin.Path(), "README.md"))
+		entries = append(entries, CatalogEntry{ID: plugin.Id(), Kind: PLUGIN_OMZ, Description: readmeDescription(string(readme))})
+	}
+	return entries, nil
+}
+
+var markdownLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// readmeDescription returns the first paragraph of a README, skipping headings, badges, html and code blocks
+func readmeDescription(readme string) string {
+	for _, paragraph := range strings.Split(strings.ReplaceAll(readme, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" || strings.HasPrefix(paragraph, "#") || strings.HasPrefix(paragraph, "!") ||
+			strings.HasPrefix(paragraph, "<") || strings.HasPrefix(paragraph, "```") || strings.HasPrefix(paragraph, "[!") {
+			continue
+		}
+		paragraph = markdownLink.ReplaceAllString(paragraph, "$1")
+		paragraph = strings.NewReplacer("**", "", "__", "").Replace(paragraph)
+		return strings.Join(strings.Fields(paragraph), " ")
+	}
+	return ""
+}
+
+// Entries returns the plugins followed by the themes
+func (c *Catalog) Entries() (entries []CatalogEntry) {
+	return append(append(entries, c.Plugins...), c.Themes...)
+}
+
+// Search returns the entries whose id, repo or description contain term, ignoring case.
+// Entries whose id matches come first.
+func (c *Catalog) Search(term string) (matches []CatalogEntry) {
+	term = strings.ToLower(term)
+	rank := map[string]int{}
+	for _, entry := range c.Entries() {
+		id := strings.ToLower(entry.ID)
+		switch {
+		case id == term:
+			rank[entry.key()] = 0
+		case strings.HasPrefix(id, term):
+			rank[entry.key()] = 1
+		case strings.Contains(id, term):
+			rank[entry.key()] = 2
+		case strings.Contains(strings.ToLower(entry.Repo), term), strings.Contains(strings.ToLower(entry.Description), term):
+			rank[entry.key()] = 3
+		default:
+			continue
+		}
+		matches = append(matches, entry)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return rank[matches[i].key()] < rank[matches[j].key()]
+	})
+	return matches
+}
+
+// Find returns the entries with id, e.g. a plugin and a theme of the same name
+func (c *Catalog) Find(id string) (entries []CatalogEntry, err error) {
+	for _, entry := range c.Entries() {
+		if entry.ID == id {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s %w", id, ErrNotInCatalog)
+	}
+	return entries, nil
+}
+
+func (e CatalogEntry) key() string {
+	return fmt.Sprintf("%s/%s/%s", e.Type, e.Kind, e.ID)
+}
+
+// Installable returns the plugin or theme of cfg installed from the repo of the entry,
+// or a new one when cfg does not contain it yet
+func (e CatalogEntry) Installable(cfg *ConfigSpec) Installable {
+	if e.Kind == PLUGIN_OMZ {
+		return &OMZPlugin{ID: e.ID}
+	}
+	if e.Type == ThemeInstallableKind {
+		for i := range cfg.Themes {
+			if cfg.Themes[i].Repo == e.Repo && cfg.Themes[i].Kind == e.Kind {
+				return &Theme{ThemeSpec: &cfg.Themes[i]}
+			}
+		}
+		return &Theme{ThemeSpec: &ThemeSpec{ID: e.ID, Name: e.ID, Repo: e.Repo, Kind: e.Kind}}
+	}
+	for i := range cfg.Plugins.Custom {
+		if cfg.Plugins.Custom[i].Repo == e.Repo && cfg.Plugins.Custom[i].Kind == e.Kind {
+			return PluginFromSpec(&cfg.Plugins.Custom[i])
+		}
+	}
+	return PluginFromSpec(&PluginSpec{ID: e.ID, Name: e.ID, Repo: e.Repo, Kind: e.Kind})
+}
+
+// InstallHint tells how to install the entry; plugins bundled with oh-my-zsh only need to be enabled
+func (e CatalogEntry) InstallHint() string {
+	source := "git:" + e.Repo
+	switch e.Kind {
+	case PLUGIN_GITHUB:
+		source = "gh:" + e.Repo
+	case PLUGIN_LOCAL, PLUGIN_URL:
+		source = string(e.Kind) + ":" + e.Repo
+	}
+	switch {
+	case e.Kind == PLUGIN_OMZ:
+		return "dfctl zsh plugins enable " + e.ID
+	case e.Type == ThemeInstallableKind:
+		return fmt.Sprintf("add {id: %s, repo: %s, kind: %s} to the themes of the config and run dfctl zsh install", e.ID, e.Repo, e.Kind)
+	case filepath.Base(e.Repo) != e.ID:
+		return fmt.Sprintf("dfctl zsh plugins install %s --id %s --name %s", source, e.ID, e.ID)
+	}
+	return "dfctl zsh plugins install " + source
+}
@@ -0,0 +1,88 @@
+package zsh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func TestReadmeDescription(t *testing.T) {
+	for name, tc := range map[string]struct {
+		readme   string
+		expected string
+	}{
+		"first paragraph": {
+			readme:   "# git plugin\n\nThe git plugin provides many\n[aliases](#aliases) and a few useful **functions**.\n\nTo use it, add `git` to the plugins array.\n",
+			expected: "The git plugin provides many aliases and a few useful functions.",
+		},
+		"badges and html": {
+			readme:   "<p align=\"center\"><img src=\"logo.png\"></p>\n\n![build](https://ci/badge.svg)\n\n## docker\r\n\r\nAdds auto-completion for docker.\r\n",
+			expected: "Adds auto-completion for docker.",
+		},
+		"code only": {readme: "# x\n\n```zsh\nplugins=(x)\n```\n", expected: ""},
+		"no readme": {readme: "", expected: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, readmeDescription(tc.readme))
+		})
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+	require.NoError(t, Save(&ConfigSpec{
+		Theme:   "simple",
+		Plugins: PluginsSpec{OMZ: OMZPluginsList{{ID: "git"}}},
+	}))
+	require.NoError(t, os.MkdirAll(filepath.Join(env.OMZ(), "plugins", "git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(env.OMZ(), "plugins", "git", "README.md"), []byte("# git\n\nGit aliases and functions.\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(env.OMZ(), "plugins", "gitfast"), 0755))
+	require.NoError(t, os.WriteFile(CatalogFile(), []byte(`plugins:
+  - id: zsh-autosuggestions
+    repo: me/zsh-autosuggestions
+    kind: github
+    description: my fork
+  - id: my-plugin
+    repo: https://example.com/my-plugin.git
+    kind: git
+`), 0644))
+
+	catalog, err := LoadCatalog()
+	require.NoError(t, err)
+	assert.Equal(t, CatalogEntry{ID: "git", Kind: PLUGIN_OMZ, Description: "Git aliases and functions.", Type: PluginInstallableKind}, catalog.Plugins[0])
+	assert.NotEmpty(t, catalog.Themes, "curated themes are bundled")
+
+	entries, err := catalog.Find("zsh-autosuggestions")
+	assert.NoError(t, err)
+	assert.Equal(t, []CatalogEntry{{ID: "zsh-autosuggestions", Repo: "me/zsh-autosuggestions", Kind: PLUGIN_GITHUB, Description: "my fork", Type: PluginInstallableKind}}, entries,
+		"the catalog file replaces bundled entries")
+	_, err = catalog.Find("my-plugin")
+	assert.NoError(t, err)
+	_, err = catalog.Find("unknown")
+	assert.ErrorIs(t, err, ErrNotInCatalog)
+
+	var ids []string
+	for _, entry := range catalog.Search("GIT") {
+		ids = append(ids, entry.ID)
+	}
+	assert.Equal(t, "git", ids[0], "exact matches come first")
+	assert.Equal(t, "gitfast", ids[1])
+	assert.Contains(t, ids, "forgit")
+	assert.Contains(t, ids, "my-plugin", "repos are searched")
+
+	cfg := MustLoad()
+	git, err := catalog.Find("git")
+	require.NoError(t, err)
+	assert.True(t, git[0].Installable(cfg).IsEnabled())
+	assert.True(t, git[0].Installable(cfg).IsInstalled())
+	assert.False(t, entries[0].Installable(cfg).IsInstalled())
+	assert.Equal(t, "dfctl zsh plugins install gh:me/zsh-autosuggestions", entries[0].InstallHint())
+}
@@ -0,0 +1,261 @@
+package zsh
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+var ErrUnknownRef = fmt.Errorf("unknown ref")
+
+// cloneOptions are the options of cloneAt; the embedded CloneSpec is expected to be fully resolved
+type cloneOptions struct {
+	url    string
+	ref    string
+	commit string
+	CloneSpec
+}
+
+func (opts cloneOptions) depth() int {
+	if opts.Depth == nil || *opts.Depth < 0 {
+		return 0
+	}
+	return *opts.Depth
+}
+
+func (opts cloneOptions) singleBranch() bool {
+	return opts.SingleBranch != nil && *opts.SingleBranch
+}
+
+// cloneDefaults returns the global clone options of the config, falling back to DefaultClone
+func cloneDefaults() CloneSpec {
+	cfg, err := Load()
+	if err != nil {
+		return DefaultClone
+	}
+	return cfg.Clone.Or(DefaultClone)
+}
+
+// cloneAt clones url into path and checks out commit, or ref when no commit is given.
+// Without both, the default branch of the remote stays checked out.
+//
+// A branch or tag ref gets cloned directly, so that shallow single branch clones contain it.
+// Commits missing from a shallow clone get fetched by deepening its history.
+// A partial clone is removed again when the checkout fails.
+func cloneAt(path string, opts cloneOptions) (err error) {
+	clone := &git.CloneOptions{URL: opts.url, Depth: opts.depth(), SingleBranch: opts.singleBranch()}
+	if opts.ref != "" || clone.SingleBranch {
+		refs, err := listRemote(opts.url)
+		if err != nil {
+			return err
+		}
+		clone.ReferenceName = remoteRef(refs, opts.ref)
+		if clone.ReferenceName == "" && clone.SingleBranch {
+			clone.ReferenceName = remoteHead(refs)
+		}
+	}
+
+	repo, err := git.PlainClone(path, false, clone)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(path)
+		}
+	}()
+
+	if opts.ref != "" {
+		if err = checkoutRef(repo, opts.ref); err != nil {
+			return err
+		}
+	}
+	if opts.commit != "" {
+		return checkoutCommit(repo, plumbing.NewHash(opts.commit))
+	}
+	return nil
+}
+
+// listRemote lists the references of the remote at url without cloning it
+func listRemote(url string) (refs []*plumbing.Reference, err error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	return remote.List(&git.ListOptions{})
+}
+
+// remoteRef returns the branch or tag of refs named ref; it is empty for commits
+func remoteRef(refs []*plumbing.Reference, ref string) plumbing.ReferenceName {
+	if ref == "" {
+		return ""
+	}
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+		for _, r := range refs {
+			if r.Name() == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// remoteHead returns the default branch of the remote.
+// Cloning a single branch without naming it would assume master otherwise.
+func remoteHead(refs []*plumbing.Reference) plumbing.ReferenceName {
+	var head *plumbing.Reference
+	for _, r := range refs {
+		if r.Name() == plumbing.HEAD {
+			head = r
+		}
+	}
+	switch {
+	case head == nil:
+		return ""
+	case head.Type() == plumbing.SymbolicReference:
+		return head.Target()
+	}
+	for _, r := range refs {
+		if r.Name().IsBranch() && r.Hash() == head.Hash() {
+			return r.Name()
+		}
+	}
+	return ""
+}
+
+// deepenSteps are the depths a shallow clone is deepened to one after another, before fetching its full history
+var deepenSteps = []int{50, 500}
+
+// fullDepth fetches the full history of a shallow clone, like git fetch --unshallow
+const fullDepth = math.MaxInt32
+
+var allRefSpecs = []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}
+
+// deepen fetches more history from origin until found succeeds.
+// Shallow clones are deepened step by step and at last get the full history of all branches and tags,
+// full clones fetch all branches and tags once.
+func deepen(repo *git.Repository, found func() error) (err error) {
+	if err = found(); err == nil {
+		return nil
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return err
+	}
+
+	var fetches []*git.FetchOptions
+	if len(shallow) > 0 {
+		for _, depth := range deepenSteps {
+			fetches = append(fetches, &git.FetchOptions{Depth: depth})
+		}
+		fetches = append(fetches, &git.FetchOptions{RefSpecs: allRefSpecs, Depth: fullDepth})
+	} else {
+		fetches = append(fetches, &git.FetchOptions{RefSpecs: allRefSpecs})
+	}
+
+	for _, fetch := range fetches {
+		if err = repo.Fetch(fetch); err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+		if err = pruneShallow(repo); err != nil {
+			return err
+		}
+		if err = found(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkoutRef checks out a branch of origin as a local branch tracking it, or a tag or commit as detached HEAD
+func checkoutRef(repo *git.Repository, ref string) (err error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if remote, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref), true); err == nil {
+		branch := plumbing.NewBranchReferenceName(ref)
+		if _, err = repo.Reference(branch, false); err == nil {
+			return worktree.Checkout(&git.CheckoutOptions{Branch: branch})
+		}
+		if err = worktree.Checkout(&git.CheckoutOptions{Branch: branch, Hash: remote.Hash(), Create: true}); err != nil {
+			return err
+		}
+		return repo.CreateBranch(&config.Branch{Name: ref, Remote: git.DefaultRemoteName, Merge: branch})
+	}
+
+	var hash *plumbing.Hash
+	err = deepen(repo, func() (err error) {
+		hash, err = repo.ResolveRevision(plumbing.Revision(ref))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", ErrUnknownRef, ref, err)
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
+
+// checkoutCommit checks out hash, fetching it from origin if it is not known yet.
+// A checked out branch gets reset to hash, so that it keeps tracking its upstream; otherwise hash is checked out as detached HEAD.
+func checkoutCommit(repo *git.Repository, hash plumbing.Hash) (err error) {
+	err = deepen(repo, func() (err error) {
+		_, err = repo.CommitObject(hash)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", ErrUnknownRef, hash, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if head, err := repo.Head(); err == nil && head.Name().IsBranch() {
+		return worktree.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Hash: hash})
+}
+
+// Checkout checks out commit in the clone at path, unless it is already checked out.
+// Clones with uncommitted changes are left alone.
+func Checkout(path, commit string) (changed bool, err error) {
+	repo, err := openRepository(path)
+	if err != nil {
+		return false, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return false, err
+	}
+	if head.Hash().String() == commit {
+		return false, nil
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+	if !status.IsClean() {
+		return false, fmt.Errorf("%s: %w", path, ErrDirtyWorktree)
+	}
+	return true, checkoutCommit(repo, plumbing.NewHash(commit))
+}
+
+// HeadCommit returns the commit checked out in the clone at path
+func HeadCommit(path string) (commit string, err error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
@@ -0,0 +1,533 @@
+package zsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec encodes and decodes a ConfigSpec to and from one of the supported config file formats.
+//
+// The yaml struct tags of ConfigSpec are the single source of truth for all formats:
+// toml and json are converted from and to an ordered *yaml.Node, so that every format
+// uses the same keys, the same custom (un)marshalling and the same ordering.
+type Codec interface {
+	Encode(cfg *ConfigSpec) (data []byte, err error)
+	Decode(data []byte, cfg *ConfigSpec) (err error)
+}
+
+// Formats lists the names of the supported config formats
+var Formats = []string{"yaml", "toml", "json"}
+
+// Extensions lists the config file extensions in order of precedence
+var Extensions = []string{".yaml", ".yml", ".toml", ".json"}
+
+var codecs = map[string]Codec{
+	".yaml": yamlCodec{},
+	".yml":  yamlCodec{},
+	".toml": tomlCodec{},
+	".json": jsonCodec{},
+}
+
+var ErrUnsupportedFormat = fmt.Errorf("unsupported config format")
+
+// CodecFor returns the Codec for the config file extension ext, e.g. `.toml`
+func CodecFor(ext string) (codec Codec, err error) {
+	if codec, ok := codecs[strings.ToLower(ext)]; ok {
+		return codec, nil
+	}
+	return nil, fmt.Errorf("%w: configFile extention %s", ErrUnsupportedFormat, ext)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Encode(cfg *ConfigSpec) ([]byte, error) {
+	node, err := encodeNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(node)
+}
+
+func (yamlCodec) Decode(data []byte, cfg *ConfigSpec) (err error) {
+	node := &yaml.Node{}
+	if err = yaml.Unmarshal(data, node); err != nil || node.Kind == 0 {
+		return err
+	}
+	return decodeNode(node, cfg)
+}
+
+func encodeNode(cfg *ConfigSpec) (node *yaml.Node, err error) {
+	node = &yaml.Node{}
+	if err = node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.DocumentNode {
+		node = node.Content[0]
+	}
+	if len(cfg.unknown) > 0 {
+		node.Style &^= yaml.FlowStyle
+		node.Content = append(node.Content, cfg.unknown...)
+	}
+	return node, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(cfg *ConfigSpec) (data []byte, err error) {
+	node, err := encodeNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err = writeJSON(buf, node); err != nil {
+		return nil, err
+	}
+	indented := &bytes.Buffer{}
+	if err = json.Indent(indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteString("\n")
+	return indented.Bytes(), nil
+}
+
+func (jsonCodec) Decode(data []byte, cfg *ConfigSpec) (err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := readJSON(dec)
+	if err != nil {
+		return err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid json: unexpected data after the top-level value")
+	}
+	return decodeNode(node, cfg)
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) (err error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteString("{")
+		for i := 0; i < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err = writeJSONString(buf, node.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteString(":")
+			if err = writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case yaml.SequenceNode:
+		buf.WriteString("[")
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err = writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!bool", "!!int", "!!float":
+			buf.WriteString(node.Value)
+		case "!!null":
+			buf.WriteString("null")
+		default:
+			return writeJSONString(buf, node.Value)
+		}
+	default:
+		return fmt.Errorf("unable to convert yaml node of kind %v to json", node.Kind)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// json.Encoder terminates each value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func readJSON(dec *json.Decoder) (node *yaml.Node, err error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for dec.More() {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return nil, fmt.Errorf("invalid json: %w", err)
+				}
+				value, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, scalarNode("!!str", keyToken.(string)), value)
+			}
+		case '[':
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for dec.More() {
+				item, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, item)
+			}
+		}
+		// consume the closing delimiter
+		if _, err = dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		return node, nil
+	case string:
+		return scalarNode("!!str", t), nil
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(t)), nil
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return scalarNode("!!int", t.String()), nil
+		}
+		return scalarNode("!!float", t.String()), nil
+	default:
+		return scalarNode("!!null", "null"), nil
+	}
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Encode(cfg *ConfigSpec) (data []byte, err error) {
+	node, err := encodeNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err = writeTOMLTable(buf, nil, node, false); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(buf.Bytes(), "\n"), nil
+}
+
+func (tomlCodec) Decode(data []byte, cfg *ConfigSpec) (err error) {
+	var raw map[string]interface{}
+	md, err := toml.Decode(string(data), &raw)
+	if err != nil {
+		return err
+	}
+
+	// toml decodes tables into maps; restore the order in which the keys appear in the document
+	order := map[string]int{}
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+
+	node, err := tomlToNode(nil, raw, order)
+	if err != nil {
+		return err
+	}
+	migrateTOMLKeys(node, reflect.TypeOf(cfg).Elem())
+	return decodeNode(node, cfg)
+}
+
+func tomlToNode(path []string, value interface{}, order map[string]int) (node *yaml.Node, err error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		position := func(key string) int {
+			if i, ok := order[toml.Key(append(append([]string{}, path...), key)).String()]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if pi, pj := position(keys[i]), position(keys[j]); pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			child, err := tomlToNode(append(append([]string{}, path...), key), v[key], order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, scalarNode("!!str", key), child)
+		}
+		return node, nil
+	case []map[string]interface{}:
+		node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			child, err := tomlToNode(path, item, order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case []interface{}:
+		node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			child, err := tomlToNode(path, item, order)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case string:
+		return scalarNode("!!str", v), nil
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(v)), nil
+	case int64:
+		return scalarNode("!!int", strconv.FormatInt(v, 10)), nil
+	case float64:
+		return scalarNode("!!float", strconv.FormatFloat(v, 'g', -1, 64)), nil
+	default:
+		return nil, fmt.Errorf("unsupported toml value %v (%T) at key %s", v, v, toml.Key(path))
+	}
+}
+
+// legacyTOMLKeys maps the keys of toml configs written before the yaml tags became the keys of every format,
+// which differ from the yaml keys by more than their case
+var legacyTOMLKeys = map[string]string{
+	"path":        "paths",
+	"zsh_options": "zshoptions",
+}
+
+// migrateTOMLKeys renames the keys of the mapping node that match a field of t case-insensitively or by their legacy name,
+// e.g. `Theme`, `[[Plugins.Custom]]` or `Configs.zsh_options`, to the yaml key of the field.
+// Only the fields of plain structs are migrated; the keys of exports, aliases and other user data stay untouched.
+func migrateTOMLKeys(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()) {
+		return
+	}
+	switch {
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			migrateTOMLKeys(item, t.Elem())
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if legacy, ok := legacyTOMLKeys[strings.ToLower(key.Value)]; ok {
+				key.Value = legacy
+			}
+			for name, field := range fields {
+				if strings.EqualFold(key.Value, name) {
+					key.Value = name
+					migrateTOMLKeys(node.Content[i+1], field.Type)
+					break
+				}
+			}
+		}
+	}
+}
+
+// yamlFields returns the exported fields of the struct type t by their yaml key
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// decodeNode decodes the mapping node into cfg.
+// Unknown top-level keys, e.g. written by a newer dfctl, are logged and kept in cfg,
+// so that saving the config again does not lose them.
+func decodeNode(node *yaml.Node, cfg *ConfigSpec) error {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.MappingNode {
+		fields := yamlFields(reflect.TypeOf(cfg).Elem())
+		known := &yaml.Node{Kind: yaml.MappingNode, Tag: node.Tag}
+		for i := 0; i < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if fields[key.Value].Name != "" {
+				known.Content = append(known.Content, key, value)
+				continue
+			}
+			log.Warn().Msgf("ignoring unknown config key %q; it is kept when saving the config", key.Value)
+			cfg.unknown = append(cfg.unknown, plainNode(key), plainNode(value))
+		}
+		node = known
+	}
+	return node.Decode(cfg)
+}
+
+// plainNode returns a copy of node without its styles, comments and positions,
+// so that unknown keys encode the same regardless of the format they got decoded from
+func plainNode(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		return plainNode(node.Alias)
+	}
+	plain := &yaml.Node{Kind: node.Kind, Tag: node.ShortTag(), Value: node.Value}
+	for _, child := range node.Content {
+		plain.Content = append(plain.Content, plainNode(child))
+	}
+	return plain
+}
+
+func isTable(node *yaml.Node) bool { return node.Kind == yaml.MappingNode }
+
+func isArrayOfTables(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if !isTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTOMLTable writes the mapping node as toml table at path.
+// Plain key / value pairs are written first, followed by sub tables and arrays of tables.
+//
+// The toml encoder only writes the tables: it sorts the keys of maps, while the order of exports, aliases and options matters,
+// and it cannot encode arrays that start with a table followed by plain values, e.g. oh-my-zsh plugins with and without load mode.
+// Keys and scalar values are quoted by the toml package.
+func writeTOMLTable(buf *bytes.Buffer, path toml.Key, node *yaml.Node, arrayElement bool) (err error) {
+	var tables []int
+	hasValues := false
+	for i := 0; i < len(node.Content); i += 2 {
+		if value := node.Content[i+1]; isTable(value) || isArrayOfTables(value) {
+			tables = append(tables, i)
+			continue
+		}
+		hasValues = true
+	}
+
+	switch {
+	case arrayElement:
+		fmt.Fprintf(buf, "\n[[%s]]\n", path)
+	case len(path) > 0 && (hasValues || len(tables) == 0):
+		fmt.Fprintf(buf, "\n[%s]\n", path)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if isTable(value) || isArrayOfTables(value) {
+			continue
+		}
+		inline, err := tomlInline(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s = %s\n", toml.Key{key.Value}, inline)
+	}
+
+	for _, i := range tables {
+		key, value := node.Content[i], node.Content[i+1]
+		childPath := append(append(toml.Key{}, path...), key.Value)
+		if isTable(value) {
+			if err = writeTOMLTable(buf, childPath, value, false); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, item := range value.Content {
+			if err = writeTOMLTable(buf, childPath, item, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tomlInline returns the node as inline toml value
+func tomlInline(node *yaml.Node) (inline string, err error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return tomlScalar(node)
+	case yaml.SequenceNode:
+		var items []string
+		for _, item := range node.Content {
+			value, err := tomlInline(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case yaml.MappingNode:
+		var pairs []string
+		for i := 0; i < len(node.Content); i += 2 {
+			value, err := tomlInline(node.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, toml.Key{node.Content[i].Value}.String()+" = "+value)
+		}
+		return "{ " + strings.Join(pairs, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("unable to convert yaml node of kind %v to toml", node.Kind)
+	}
+}
+
+// tomlScalar returns the scalar node as toml value, encoded by the toml package
+func tomlScalar(node *yaml.Node) (string, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", fmt.Errorf("toml does not support null values")
+	}
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(map[string]interface{}{"v": value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(buf.String(), "v = "), "\n"), nil
+}
@@ -0,0 +1,159 @@
+package zsh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	cfg := &ConfigSpec{
+		Theme: "powerlevel10k/powerlevel10k",
+		Plugins: PluginsSpec{
+			OMZ: append(OMZPluginList("git", "brew"), OMZPlugin{ID: "nvm", Load: LoadOnCommand("nvm", "node")}),
+			Custom: PluginsList{
+				{
+					ID:      "zsh-autosuggestions",
+					Name:    "zsh-autosuggestions",
+					Repo:    "zsh-users/zsh-autosuggestions",
+					Kind:    PLUGIN_GITHUB,
+					Load:    LOAD_DEFERRED,
+					Enabled: true,
+				},
+			},
+		},
+		Themes: ThemesSpec{
+			{
+				ID:   "powerlevel10k/powerlevel10k",
+				Name: "powerlevel10k",
+				Repo: "romkatv/powerlevel10k",
+				Kind: PLUGIN_GITHUB,
+			},
+		},
+		Exports: ValuesOf(map[string]string{
+			"GOPATH":              "$HOME/go",
+			"FZF_DEFAULT_COMMAND": "rg --files -g '!{.git,node_modules}/*' \"quoted\"\t2> /dev/null",
+		}),
+		Aliases: KeyValues{
+			{Key: "reload!", Value: "source <(dfctl zsh source)"},
+			{Key: "k", Value: "kubectl"},
+		},
+		Source: SourceSpec{
+			Post: ValueListOf("~/.p10k.zsh"),
+		},
+		Configs: ConfigsSpec{
+			Paths:      PathListOf("$GOBIN"),
+			ZshOptions: Options{{Name: "beep", Enabled: false}, {Name: "autocd", Enabled: true}},
+		},
+	}
+
+	for _, ext := range Extensions {
+		t.Run(ext, func(t *testing.T) {
+			formatted, err := cfg.Format(func(f *ConfigFormatter) { f.ConfigFileType = ext })
+			assert.NoError(t, err)
+
+			parsed, err := Parse([]byte(formatted), ext)
+			assert.NoError(t, err)
+
+			equivalent, err := Equivalent(cfg, parsed)
+			assert.NoError(t, err)
+			assert.True(t, equivalent, formatted)
+		})
+	}
+}
+
+func TestCodecFor_Unsupported(t *testing.T) {
+	_, err := CodecFor(".ini")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+// legacyTOML is a config in the toml format written before the yaml tags became the keys of every format
+const legacyTOML = `Theme = "powerlevel10k/powerlevel10k"
+
+[Plugins]
+  OMZ = ["git", "brew"]
+
+  [[Plugins.Custom]]
+    id = "zsh-autosuggestions"
+    name = "zsh-autosuggestions"
+    repo = "zsh-users/zsh-autosuggestions"
+    kind = "github"
+    enabled = true
+
+[[Themes]]
+  ID = "powerlevel10k/powerlevel10k"
+  Name = "powerlevel10k"
+  Repo = "romkatv/powerlevel10k"
+  Kind = "github"
+
+[Exports]
+  GOPATH = "$HOME/go"
+
+[Configs]
+  path = ["$GOBIN"]
+  [Configs.user]
+    EDITOR = "nvim"
+  [Configs.zsh_options]
+    autocd = true
+
+[Source]
+  post = ["~/.p10k.zsh"]
+
+[Aliases]
+  K = "kubectl"
+`
+
+func TestTOMLCodec_DecodeLegacy(t *testing.T) {
+	parsed, err := Parse([]byte(legacyTOML), ".toml")
+	assert.NoError(t, err)
+
+	expected := &ConfigSpec{
+		Theme: "powerlevel10k/powerlevel10k",
+		Plugins: PluginsSpec{
+			OMZ: OMZPluginList("git", "brew"),
+			Custom: PluginsList{{
+				ID:      "zsh-autosuggestions",
+				Name:    "zsh-autosuggestions",
+				Repo:    "zsh-users/zsh-autosuggestions",
+				Kind:    PLUGIN_GITHUB,
+				Enabled: true,
+			}},
+		},
+		Themes:  ThemesSpec{{ID: "powerlevel10k/powerlevel10k", Name: "powerlevel10k", Repo: "romkatv/powerlevel10k", Kind: PLUGIN_GITHUB}},
+		Exports: Values{{Name: "GOPATH", Value: Value{Value: "$HOME/go"}}},
+		Configs: ConfigsSpec{
+			Paths:      PathListOf("$GOBIN"),
+			User:       Values{{Name: "EDITOR", Value: Value{Value: "nvim"}}},
+			ZshOptions: Options{{Name: "autocd", Enabled: true}},
+		},
+		Source:  SourceSpec{Post: ValueListOf("~/.p10k.zsh")},
+		Aliases: KeyValues{{Key: "K", Value: "kubectl"}},
+	}
+	equivalent, err := Equivalent(expected, parsed)
+	assert.NoError(t, err)
+	assert.True(t, equivalent, "%+v", parsed)
+}
+
+func TestCodec_UnknownKeys(t *testing.T) {
+	for ext, data := range map[string]string{
+		".yaml": "theme: simple\nthemse: [agnoster]\n",
+		".toml": "theme = \"simple\"\nthemse = [\"agnoster\"]\n",
+		".json": `{"theme": "simple", "themse": ["agnoster"]}`,
+	} {
+		cfg, err := Parse([]byte(data), ext)
+		assert.NoError(t, err, ext)
+		assert.Equal(t, "simple", cfg.Theme, ext)
+
+		for _, to := range Formats {
+			formatted, err := cfg.Format(func(f *ConfigFormatter) { f.ConfigFileType = "." + to })
+			assert.NoError(t, err, ext)
+			assert.Contains(t, formatted, "themse", "%s to %s keeps the unknown key", ext, to)
+
+			parsed, err := Parse([]byte(formatted), "."+to)
+			assert.NoError(t, err, ext)
+			equivalent, err := Equivalent(cfg, parsed)
+			assert.NoError(t, err)
+			assert.True(t, equivalent, "%s to %s", ext, to)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package zsh
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"==", "!=", "=~", "&&", "||", "!", "(", ")"}
+
+// tokenize splits condition into tokens; it decodes condition as utf-8, so that identifiers may contain any letters
+func tokenize(condition string) (tokens []token, err error) {
+	for i := 0; i < len(condition); {
+		c, size := utf8.DecodeRuneInString(condition[i:])
+		switch {
+		case c == utf8.RuneError && size <= 1:
+			return nil, fmt.Errorf("%s: invalid utf-8 at byte %d", condition, i)
+		case unicode.IsSpace(c):
+			i += size
+		case c == '"':
+			end := i + 1
+			for ; end < len(condition) && condition[end] != '"'; end++ {
+				if condition[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(condition) {
+				return nil, fmt.Errorf("%s: unterminated string", condition)
+			}
+			text, err := strconv.Unquote(condition[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid string %s", condition, condition[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, text})
+			i = end + 1
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(condition) {
+				r, size := utf8.DecodeRuneInString(condition[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{tokenIdent, condition[i:end]})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(condition[i:], op) {
+					tokens = append(tokens, token{tokenOperator, op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%s: unexpected character %q", condition, c)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser is a recursive descent parser evaluating conditions while parsing them
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" or ")" | operand [ ( "==" | "!=" | "=~" ) operand ]
+//	operand = fact | string
+type conditionParser struct {
+	tokens []token
+	pos    int
+	facts  Facts
+}
+
+func (p *conditionParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *conditionParser) peek() token {
+	if p.done() {
+		return token{tokenOperator, ""}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op && !p.done() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (ok bool, err error) {
+	if ok, err = p.parseAnd(); err != nil {
+		return false, err
+	}
+	for p.accept("||") {
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		ok = ok || rhs
+	}
+	return ok, nil
+}
+
+func (p *conditionParser) parseAnd() (ok bool, err error) {
+	if ok, err = p.parseUnary(); err != nil {
+		return false, err
+	}
+	for p.accept("&&") {
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return false, err
+		}
+		ok = ok && rhs
+	}
+	return ok, nil
+}
+
+func (p *conditionParser) parseUnary() (ok bool, err error) {
+	if p.accept("!") {
+		ok, err = p.parseUnary()
+		return !ok, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *conditionParser) parsePrimary() (ok bool, err error) {
+	if p.accept("(") {
+		if ok, err = p.parseOr(); err != nil {
+			return false, err
+		}
+		if !p.accept(")") {
+			return false, fmt.Errorf("missing closing parenthesis")
+		}
+		return ok, nil
+	}
+
+	lhs, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case p.accept("=="):
+		rhs, err := p.parseOperand()
+		return lhs == rhs, err
+	case p.accept("!="):
+		rhs, err := p.parseOperand()
+		return lhs != rhs, err
+	case p.accept("=~"):
+		rhs, err := p.parseOperand()
+		if err != nil {
+			return false, err
+		}
+		pattern, err := regexp.Compile(rhs)
+		if err != nil {
+			return false, err
+		}
+		return pattern.MatchString(lhs), nil
+	default:
+		return lhs != "", nil
+	}
+}
+
+func (p *conditionParser) parseOperand() (value string, err error) {
+	if p.done() {
+		return "", fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	switch t.kind {
+	case tokenString:
+		p.pos++
+		return t.text, nil
+	case tokenIdent:
+		p.pos++
+		return p.facts.Lookup(t.text)
+	default:
+		return "", fmt.Errorf("unexpected %q", t.text)
+	}
+}
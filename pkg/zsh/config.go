@@ -2,13 +2,13 @@ package zsh
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/BurntSushi/toml"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
@@ -19,11 +19,40 @@ import (
 )
 
 type PluginSpec struct {
-	ID      string   `toml:"id,required"`
-	Name    string   `toml:"name,omitempty"`
-	Repo    string   `toml:"repo,omitempty"`
-	Kind    RepoKind `toml:"kind,omitempty"`
-	Enabled bool     `toml:"enabled,required"`
+	ID   string   `yaml:"id"`
+	Name string   `yaml:"name,omitempty"`
+	Repo string   `yaml:"repo,omitempty"`
+	Kind RepoKind `yaml:"kind,omitempty"`
+	// Ref is the branch, tag or commit to install instead of the default branch
+	Ref   string    `yaml:"ref,omitempty"`
+	Clone CloneSpec `yaml:"clone,omitempty"`
+	// After and Before list the ids of plugins this plugin loads after or before, if they are enabled
+	After    []string     `yaml:"after,omitempty"`
+	Before   []string     `yaml:"before,omitempty"`
+	Requires RequiresSpec `yaml:"requires,omitempty"`
+	// Subdir is the directory of the checkout the plugin lives in, e.g. modules/git of prezto
+	Subdir string `yaml:"subdir,omitempty"`
+	// File is the single file of the checkout to source instead of a plugin directory, like a snippet of antigen or zinit
+	File string `yaml:"file,omitempty"`
+	// Init is the file of the plugin directory to source instead of the discovered init file
+	Init string `yaml:"init,omitempty"`
+	// Checksum verifies the archive of url plugins, e.g. sha256:<hex>
+	Checksum string `yaml:"checksum,omitempty"`
+	// Load is eager, deferred or on-command:<cmd>[,<cmd>...]
+	Load    LoadMode `yaml:"load,omitempty"`
+	Enabled bool     `yaml:"enabled"`
+}
+
+// RequiresSpec lists the plugins which need to be enabled and the executables which need to be on $PATH for a plugin to work.
+// A plugin loads after the plugins it requires.
+type RequiresSpec struct {
+	Plugins     []string `yaml:"plugins,omitempty"`
+	Executables []string `yaml:"executables,omitempty"`
+}
+
+// IsZero reports whether nothing is required, omitting empty requirements when marshalling
+func (spec RequiresSpec) IsZero() bool {
+	return len(spec.Plugins) == 0 && len(spec.Executables) == 0
 }
 
 type RepoKind string
@@ -36,36 +65,52 @@ func (k *RepoKind) Order() int {
 		return 1
 	case PLUGIN_OMZ:
 		return 2
+	case PLUGIN_LOCAL:
+		return 3
+	case PLUGIN_URL:
+		return 4
 	default:
 		return 1000
 	}
 }
 
+// IsGit reports whether plugins and themes of the kind are git clones
+func (k RepoKind) IsGit() bool {
+	return k == PLUGIN_GITHUB || k == PLUGIN_GIT
+}
+
 const (
 	PLUGIN_GITHUB RepoKind = "github"
 	PLUGIN_GIT    RepoKind = "git"
 	PLUGIN_OMZ    RepoKind = "omz"
+	// PLUGIN_LOCAL is a local directory, symlinked into the plugins dir and sourced in place
+	PLUGIN_LOCAL RepoKind = "local"
+	// PLUGIN_URL is a tar or zip archive downloaded over http(s) and verified by its checksum
+	PLUGIN_URL RepoKind = "url"
 )
 
+var RepoKinds = []RepoKind{PLUGIN_GITHUB, PLUGIN_GIT, PLUGIN_OMZ, PLUGIN_LOCAL, PLUGIN_URL}
+
 type PluginsList []PluginSpec
 type OMZPluginsList []OMZPlugin
 
 func (o *OMZPluginsList) UnmarshalYAML(value *yaml.Node) error {
-	var plugins []string
+	var plugins []OMZPlugin
 	if err := value.Decode(&plugins); err != nil {
 		return err
 	}
-
-	for _, plugin := range plugins {
-		*o = append(*o, OMZPlugin{plugin})
-	}
+	*o = append(*o, plugins...)
 	return nil
 }
 
 func (o OMZPluginsList) MarshalYAML() (interface{}, error) {
-	var plugins []string
+	var plugins []interface{}
 	for _, plugin := range o {
-		plugins = append(plugins, plugin.ID)
+		plugin, err := plugin.MarshalYAML()
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
 	}
 	return plugins, nil
 }
@@ -99,8 +144,8 @@ func OMZPluginList(omzs ...string) (plugins OMZPluginsList) {
 }
 
 type PluginsSpec struct {
-	OMZ    OMZPluginsList `json:"omz,omitempty"`
-	Custom PluginsList    `json:"custom,omitempty"`
+	OMZ    OMZPluginsList `yaml:"omz,omitempty"`
+	Custom PluginsList    `yaml:"custom,omitempty"`
 }
 
 func (omzs OMZPluginsList) PluginIDs() (plugins []string) {
@@ -119,30 +164,35 @@ func (s PluginsSpec) GetOMZ(id string) (omz *OMZPlugin, ok bool) {
 	return nil, false
 }
 
-func ParsePluginKind(kindStr string) RepoKind {
-	var kind RepoKind
+var ErrUnsupportedPluginKind = fmt.Errorf("unsupported plugin kind")
+
+// ParsePluginKind parses the [type] of a plugin urn, e.g. gh for gh:zsh-users/zsh-autosuggestions
+func ParsePluginKind(kindStr string) (RepoKind, error) {
 	switch strings.ToLower(kindStr) {
 	case "omz":
-		kind = PLUGIN_OMZ
+		return PLUGIN_OMZ, nil
 	case "git":
-		kind = PLUGIN_GIT
-	case "gh":
-		kind = PLUGIN_GITHUB
+		return PLUGIN_GIT, nil
+	case "gh", "github":
+		return PLUGIN_GITHUB, nil
+	case "local":
+		return PLUGIN_LOCAL, nil
+	case "url":
+		return PLUGIN_URL, nil
 	default:
-		err := fmt.Errorf("kind %s is not supported", kindStr)
-		log.Error().Err(err).Msgf("failed to parse RepoKind")
-		panic(err)
+		return "", fmt.Errorf("%w %q; expected one of omz, git, gh, local or url", ErrUnsupportedPluginKind, kindStr)
 	}
-	return kind
 }
 
 type PluginURN string
 
-func (p PluginURN) GetScheme() RepoKind {
+func (p PluginURN) GetScheme() (RepoKind, error) {
 	urn := string(p)
 	i := strings.Index(urn, ":")
-	scheme := urn[:i]
-	return ParsePluginKind(scheme)
+	if i < 0 {
+		return "", fmt.Errorf("%w: %s has no [type]: prefix", ErrUnsupportedPluginKind, urn)
+	}
+	return ParsePluginKind(urn[:i])
 }
 
 func (p PluginURN) GetURI() string {
@@ -188,15 +238,15 @@ func (s *PluginsSpec) ContainsWithRepo(repo string, kind RepoKind) bool {
 }
 
 type SourceSpec struct {
-	Pre  []string `toml:"pre,omitempty"`
-	Post []string `toml:"post,omitempty"`
+	Pre  ValueList `yaml:"pre,omitempty"`
+	Post ValueList `yaml:"post,omitempty"`
 }
 
 type ConfigsSpec struct {
-	Paths      []string          `toml:"path,omitempty"`
-	User       map[string]string `toml:"user,omitempty"`
-	OMZ        map[string]string `toml:"omz,omitempty"`
-	ZshOptions map[string]bool   `toml:"zsh_options,omitempty"`
+	Paths      PathList  `yaml:"paths,omitempty"`
+	User       Values    `yaml:"user,omitempty"`
+	OMZ        KeyValues `yaml:"omz,omitempty"`
+	ZshOptions Options   `yaml:"zshoptions,omitempty"`
 }
 
 type ThemesSpec []ThemeSpec
@@ -205,16 +255,70 @@ type ThemeSpec struct {
 	Name string   `yaml:"name,omitempty"`
 	Repo string   `yaml:"repo,omitempty"`
 	Kind RepoKind `yaml:"kind,omitempty"`
+	// Ref is the branch, tag or commit to install instead of the default branch
+	Ref   string    `yaml:"ref,omitempty"`
+	Clone CloneSpec `yaml:"clone,omitempty"`
+}
+
+// CloneSpec configures how plugins and themes get cloned.
+// Unset options fall back to the global clone options of the config and then to DefaultClone.
+//
+//	clone:
+//	  depth: 1          # 0 clones the full history
+//	  singlebranch: true
+type CloneSpec struct {
+	Depth        *int  `yaml:"depth,omitempty"`
+	SingleBranch *bool `yaml:"singlebranch,omitempty"`
+}
+
+// DefaultClone clones only the latest commit of a single branch, so that first installs finish fast.
+// The history gets deepened on demand, e.g. to check out an older commit.
+var DefaultClone = CloneSpec{Depth: intptr(1), SingleBranch: boolptr(true)}
+
+// Or returns the options of spec, falling back to the ones of defaults for each unset option
+func (spec CloneSpec) Or(defaults CloneSpec) CloneSpec {
+	if spec.Depth == nil {
+		spec.Depth = defaults.Depth
+	}
+	if spec.SingleBranch == nil {
+		spec.SingleBranch = defaults.SingleBranch
+	}
+	return spec
+}
+
+// IsZero reports whether no option is set, omitting empty clone options when marshalling
+func (spec CloneSpec) IsZero() bool {
+	return spec.Depth == nil && spec.SingleBranch == nil
+}
+
+func intptr(i int) *int {
+	return &i
+}
+
+func boolptr(b bool) *bool {
+	return &b
 }
 
 type ConfigSpec struct {
-	Theme   string            `yaml:"theme,omitempty"`
-	Plugins PluginsSpec       `yaml:"plugins,omitempty"`
-	Themes  ThemesSpec        `yaml:"themes,omitempty"`
-	Exports map[string]string `yaml:"exports,omitempty"`
-	Configs ConfigsSpec       `yaml:"configs,omitempty"`
-	Source  SourceSpec        `yaml:"source,omitempty"`
-	Aliases map[string]string `yaml:"aliases,omitempty"`
+	Theme     string      `yaml:"theme,omitempty"`
+	Framework Framework   `yaml:"framework,omitempty"`
+	Plugins   PluginsSpec `yaml:"plugins,omitempty"`
+	Themes    ThemesSpec  `yaml:"themes,omitempty"`
+	Exports   Values      `yaml:"exports,omitempty"`
+	Configs   ConfigsSpec `yaml:"configs,omitempty"`
+	Source    SourceSpec  `yaml:"source,omitempty"`
+	Aliases   KeyValues   `yaml:"aliases,omitempty"`
+	Clone     CloneSpec   `yaml:"clone,omitempty"`
+
+	origin *origin
+	// unknown holds the key / value nodes of unknown top-level keys, which are written back on save
+	unknown []*yaml.Node
+}
+
+// origin records the file a ConfigSpec got loaded from, to detect external modifications before saving
+type origin struct {
+	path     string
+	checksum [sha256.Size]byte
 }
 
 type ConfigFormatter struct {
@@ -235,48 +339,189 @@ func NewConfigFormatter(opts ...ConfigFormatterOption) (f *ConfigFormatter) {
 func (cfg *ConfigSpec) Format(opts ...ConfigFormatterOption) (formatted string, err error) {
 	formatter := NewConfigFormatter(opts...)
 
-	switch formatter.ConfigFileType {
-	case ".yaml", ".yml":
-		if data, err := yaml.Marshal(cfg); err == nil {
-			return string(data), nil
-		}
-	case ".toml":
-		buf := &bytes.Buffer{}
-		if err = toml.NewEncoder(buf).Encode(cfg); err == nil {
-			return buf.String(), nil
-		}
+	codec, err := CodecFor(formatter.ConfigFileType)
+	if err != nil {
+		return "", err
 	}
-	return "", err
+	data, err := codec.Encode(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
+// Equivalent reports whether both configs are semantically identical
+func Equivalent(a, b *ConfigSpec) (equivalent bool, err error) {
+	aData, err := yamlCodec{}.Encode(a)
+	if err != nil {
+		return false, err
+	}
+	bData, err := yamlCodec{}.Encode(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aData, bData), nil
+}
+
+const (
+	configFilePerm os.FileMode = 0644
+	configDirPerm  os.FileMode = 0755
+)
+
+var ErrConfigModified = fmt.Errorf("config file has been modified by another process since it was loaded")
+
 func SaveToPath(cfg *ConfigSpec, path string) (err error) {
-	err = factory.Default.Fs.MkdirAll(filepath.Dir(path), os.ModePerm)
+	unlock, err := lockConfig(path)
 	if err != nil {
 		return err
 	}
-	switch filepath.Ext(path) {
-	case ".yaml", ".yml":
-		if data, err := yaml.Marshal(cfg); err == nil {
-			err = afero.WriteFile(factory.Default.Fs, path, data, os.ModePerm)
-			return err
+	defer unlock()
+	return saveToPath(cfg, path)
+}
+
+// saveToPath writes cfg to path; the caller must hold the config lock
+func saveToPath(cfg *ConfigSpec, path string) (err error) {
+	return writeConfig(cfg, path, true)
+}
+
+// writeConfig writes cfg to path and, if snapshot is set, keeps the replaced config in its history
+func writeConfig(cfg *ConfigSpec, path string, snapshot bool) (err error) {
+	codec, err := CodecFor(filepath.Ext(path))
+	if err != nil {
+		log.Error().Err(err).Msgf("unable to save config to path %s", path)
+		return err
+	}
+	data, err := codec.Encode(cfg)
+	if err != nil {
+		return err
+	}
+
+	current, err := afero.ReadFile(factory.Default.Fs, path)
+	switch {
+	case err == nil:
+		if cfg.origin != nil && cfg.origin.path == path && cfg.origin.checksum != sha256.Sum256(current) {
+			return fmt.Errorf("unable to save %s: %w", path, ErrConfigModified)
 		}
+		if bytes.Equal(current, data) {
+			return nil
+		}
+	case !os.IsNotExist(err):
 		return err
-	case ".toml":
-		b := &bytes.Buffer{}
-		if err = toml.NewEncoder(b).Encode(cfg); err == nil {
-			err = afero.WriteFile(factory.Default.Fs, path, b.Bytes(), os.ModePerm)
+	}
+
+	err = factory.Default.Fs.MkdirAll(filepath.Dir(path), configDirPerm)
+	if err != nil {
+		return err
+	}
+	if snapshot {
+		if err = takeSnapshot(path); err != nil {
 			return err
 		}
+	}
+	if err = writeFileAtomic(factory.Default.Fs, path, data, configFilePerm); err != nil {
 		return err
-	default:
-		err = fmt.Errorf("unsupported configFile extention %s", filepath.Ext(path))
-		log.Error().Err(err).Msgf("unable to save config to path %s", path)
-		panic(err)
 	}
+	cfg.origin = &origin{path: path, checksum: sha256.Sum256(data)}
+	return nil
+}
+
+// WithOriginOf makes cfg replace loaded, e.g. after editing a copy of it:
+// saving cfg fails with ErrConfigModified, if the file loaded got read from has been modified since.
+func (cfg *ConfigSpec) WithOriginOf(loaded *ConfigSpec) *ConfigSpec {
+	cfg.origin = loaded.origin
+	return cfg
 }
 
 func Save(cfg *ConfigSpec) (err error) {
-	return SaveToPath(cfg, env.ConfigFile())
+	return SaveToPath(cfg, ConfigFile())
+}
+
+// Update loads the config, applies the mutation fn and saves the config while holding the config lock,
+// so that concurrent dfctl processes can not interleave their read-modify-write cycles.
+func Update(fn func(cfg *ConfigSpec) error) (err error) {
+	path := ConfigFile()
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		return err
+	}
+	if err = fn(cfg); err != nil {
+		return err
+	}
+	return saveToPath(cfg, path)
+}
+
+// ConfigFile resolves the path of the config file.
+// Unless $DFCTL_CONFIG is set, the first existing dfctl config in $DFCTL_HOME is used,
+// so that a config converted into another format is picked up without further changes.
+func ConfigFile() string {
+	path := env.ConfigFile()
+	if env.GetVars().IsSet("DFCTL_CONFIG") || env.Overrides.ConfigFile != "" {
+		return path
+	}
+	if _, err := factory.Default.Fs.Stat(path); err == nil {
+		return path
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range Extensions {
+		if _, err := factory.Default.Fs.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return path
+}
+
+var ErrConversionLossy = fmt.Errorf("converted config is not identical to the original")
+
+// Convert rewrites the config file at path into the format matching ext and removes the original file.
+// The converted config gets validated to be semantically identical to the original before anything is written.
+func Convert(path, ext string) (converted string, err error) {
+	if _, err = CodecFor(ext); err != nil {
+		return path, err
+	}
+	converted = strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	if converted == path {
+		return path, nil
+	}
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return path, err
+	}
+	defer unlock()
+
+	if _, err = factory.Default.Fs.Stat(converted); err == nil {
+		return path, fmt.Errorf("unable to convert %s: %s already exists", path, converted)
+	}
+
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		return path, err
+	}
+	formatted, err := cfg.Format(func(f *ConfigFormatter) { f.ConfigFileType = ext })
+	if err != nil {
+		return path, err
+	}
+	parsed, err := Parse([]byte(formatted), ext)
+	if err != nil {
+		return path, fmt.Errorf("%w: %v", ErrConversionLossy, err)
+	}
+	if equivalent, err := Equivalent(cfg, parsed); err != nil || !equivalent {
+		return path, ErrConversionLossy
+	}
+
+	if err = writeFileAtomic(factory.Default.Fs, converted, []byte(formatted), configFilePerm); err != nil {
+		return path, err
+	}
+	if err = takeSnapshot(path); err != nil {
+		return path, err
+	}
+	return converted, factory.Default.Fs.Remove(path)
 }
 
 func LoadFromPath(path string) (cfg *ConfigSpec, err error) {
@@ -284,19 +529,23 @@ func LoadFromPath(path string) (cfg *ConfigSpec, err error) {
 	if err != nil {
 		return nil, err
 	}
-	cfg = &ConfigSpec{}
-
-	switch filepath.Ext(path) {
-	case ".yaml", ".yml":
-		if err = yaml.Unmarshal(data, cfg); err != nil {
-			return nil, err
-		}
-	case ".toml":
-		if err = toml.Unmarshal(data, cfg); err != nil {
-			return nil, err
-		}
+	if cfg, err = Parse(data, filepath.Ext(path)); err != nil {
+		return nil, err
 	}
+	cfg.origin = &origin{path: path, checksum: sha256.Sum256(data)}
+	return cfg, nil
+}
 
+// Parse decodes data using the config format matching the file extension ext
+func Parse(data []byte, ext string) (cfg *ConfigSpec, err error) {
+	codec, err := CodecFor(ext)
+	if err != nil {
+		return nil, err
+	}
+	cfg = &ConfigSpec{}
+	if err = codec.Decode(data, cfg); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
@@ -315,25 +564,25 @@ func Default() (cfg *ConfigSpec) {
 			OMZ:    []OMZPlugin{},
 			Custom: PluginsList{},
 		},
-		Aliases: map[string]string{},
+		Aliases: KeyValues{},
 		Source: SourceSpec{
-			Post: []string{},
-			Pre:  []string{},
+			Post: ValueList{},
+			Pre:  ValueList{},
 		},
 		Configs: ConfigsSpec{
-			User:       map[string]string{},
-			OMZ:        map[string]string{},
-			Paths:      []string{},
-			ZshOptions: map[string]bool{},
+			User:       Values{},
+			OMZ:        KeyValues{},
+			Paths:      PathList{},
+			ZshOptions: Options{},
 		},
-		Exports: map[string]string{},
+		Exports: Values{},
 		Themes:  ThemesSpec{},
 	}
 }
 func MustLoad() (cfg *ConfigSpec) {
-	return MustLoadFromPath(env.ConfigFile())
+	return MustLoadFromPath(ConfigFile())
 }
 
 func Load() (cfg *ConfigSpec, err error) {
-	return LoadFromPath(env.ConfigFile())
+	return LoadFromPath(ConfigFile())
 }
@@ -0,0 +1,118 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+	"github.com/alex-held/dfctl-kit/pkg/system"
+)
+
+// Facts describe the machine a config gets rendered on.
+// They are available to interpolations `${{ os }}` and conditions `when: os == "darwin"` of config values.
+//
+//	os        runtime os, e.g. darwin, linux
+//	arch      runtime arch, e.g. amd64, arm64
+//	hostname  hostname of the machine
+//	user      name of the current user
+//	home      home directory of the current user
+//	env.NAME  value of the environment variable NAME
+type Facts struct {
+	OS       string
+	Arch     string
+	Hostname string
+	User     string
+	Home     string
+	Env      env.Vars
+}
+
+var ErrUnknownFact = fmt.Errorf("unknown fact")
+
+// CurrentFacts gathers the facts of the machine dfctl is running on
+func CurrentFacts() (facts Facts) {
+	ri := system.Get()
+	vars := env.GetVars()
+
+	facts = Facts{
+		OS:   ri.OS,
+		Arch: ri.Arch,
+		Home: vars.Get("HOME"),
+		User: vars.Get("USER"),
+		Env:  vars,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		facts.Hostname = hostname
+	}
+	if facts.User == "" {
+		if u, err := user.Current(); err == nil {
+			facts.User = u.Username
+		}
+	}
+	return facts
+}
+
+// Lookup returns the value of the fact name
+func (f Facts) Lookup(name string) (value string, err error) {
+	switch name {
+	case "os":
+		return f.OS, nil
+	case "arch":
+		return f.Arch, nil
+	case "hostname":
+		return f.Hostname, nil
+	case "user":
+		return f.User, nil
+	case "home":
+		return f.Home, nil
+	}
+	if strings.HasPrefix(name, "env.") {
+		return f.Env.Get(strings.TrimPrefix(name, "env.")), nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFact, name)
+}
+
+var interpolationPattern = regexp.MustCompile(`\$\{\{\s*([^}]*?)\s*}}`)
+
+// Interpolate replaces all `${{ fact }}` placeholders in value with the value of the fact
+func (f Facts) Interpolate(value string) (interpolated string, err error) {
+	interpolated = interpolationPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := interpolationPattern.FindStringSubmatch(placeholder)[1]
+		resolved, lookupErr := f.Lookup(name)
+		if lookupErr != nil && err == nil {
+			err = lookupErr
+		}
+		return resolved
+	})
+	if err != nil {
+		return "", err
+	}
+	return interpolated, nil
+}
+
+// Eval evaluates the condition against the facts; an empty condition always holds.
+//
+// Conditions compare facts and "quoted strings" using == and != or match them
+// against a regular expression using =~. A fact on its own holds when it is not empty.
+// Comparisons can be combined with !, && and || and grouped by parentheses, e.g.
+//
+//	os == "darwin" && !(hostname =~ "^ci-")
+func (f Facts) Eval(condition string) (ok bool, err error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+	tokens, err := tokenize(condition)
+	if err != nil {
+		return false, err
+	}
+	p := &conditionParser{tokens: tokens, facts: f}
+	if ok, err = p.parseOr(); err != nil {
+		return false, fmt.Errorf("%s: %w", condition, err)
+	}
+	if !p.done() {
+		return false, fmt.Errorf("%s: unexpected %q", condition, p.peek().text)
+	}
+	return ok, nil
+}
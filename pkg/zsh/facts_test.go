@@ -0,0 +1,105 @@
+package zsh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+var testFacts = Facts{
+	OS:       "darwin",
+	Arch:     "arm64",
+	Hostname: "ci-runner-1",
+	User:     "alex",
+	Home:     "/Users/alex",
+	Env:      env.Vars{"EDITOR": "nvim"},
+}
+
+func TestFacts_Eval(t *testing.T) {
+	tt := []struct {
+		name      string
+		condition string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "empty", condition: "", want: true},
+		{name: "equal", condition: `os == "darwin"`, want: true},
+		{name: "not equal", condition: `os != "darwin"`, want: false},
+		{name: "regex", condition: `hostname =~ "^ci-"`, want: true},
+		{name: "negation", condition: `!(arch == "amd64")`, want: true},
+		{name: "and", condition: `os == "darwin" && arch == "amd64"`, want: false},
+		{name: "or", condition: `os == "linux" || arch == "arm64"`, want: true},
+		{name: "precedence", condition: `os == "linux" && arch == "amd64" || user == "alex"`, want: true},
+		{name: "env", condition: `env.EDITOR == "nvim"`, want: true},
+		{name: "truthy fact", condition: `env.EDITOR`, want: true},
+		{name: "unset env", condition: `env.UNSET`, want: false},
+		{name: "non-ascii env", condition: `env.ÜBER_EDITOR`, want: false},
+		{name: "non-ascii string", condition: `user != "jürgen"`, want: true},
+		{name: "non-ascii operator", condition: `os == "darwin" ∧ arch == "amd64"`, wantErr: true},
+		{name: "invalid utf-8", condition: "os == \"darwin\" \xff", wantErr: true},
+		{name: "unknown fact", condition: `distro == "arch"`, wantErr: true},
+		{name: "unterminated string", condition: `os == "darwin`, wantErr: true},
+		{name: "missing parenthesis", condition: `(os == "darwin"`, wantErr: true},
+		{name: "trailing tokens", condition: `os "darwin"`, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := testFacts.Eval(tc.condition)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFacts_Interpolate(t *testing.T) {
+	got, err := testFacts.Interpolate("${{ home }}/go:${{env.EDITOR}} $HOME")
+	assert.NoError(t, err)
+	assert.Equal(t, "/Users/alex/go:nvim $HOME", got)
+
+	_, err = testFacts.Interpolate("${{ distro }}")
+	assert.ErrorIs(t, err, ErrUnknownFact)
+}
+
+func TestValues_Resolve(t *testing.T) {
+	var cfg struct {
+		Exports Values    `yaml:"exports"`
+		Paths   ValueList `yaml:"paths"`
+	}
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+exports:
+  GOPATH: ${{ home }}/go
+  BROWSER:
+    value: open -a Safari
+    when: os == "darwin"
+  PAGER:
+    value: less
+    when: os == "linux"
+paths:
+  - /opt/homebrew/bin
+  - value: /usr/local/go/bin
+    when: os == "linux"
+`), &cfg))
+
+	exports, err := cfg.Exports.Resolve(testFacts)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyValues{
+		{Key: "GOPATH", Value: "/Users/alex/go"},
+		{Key: "BROWSER", Value: "open -a Safari"},
+	}, exports)
+
+	paths, err := cfg.Paths.Resolve(testFacts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/opt/homebrew/bin"}, paths)
+
+	out, err := yaml.Marshal(cfg.Paths)
+	assert.NoError(t, err)
+	assert.Equal(t, "- /opt/homebrew/bin\n- value: /usr/local/go/bin\n  when: os == \"linux\"\n", string(out))
+}
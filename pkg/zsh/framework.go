@@ -0,0 +1,88 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+// Framework selects how the rendered zshrc loads plugins and themes
+type Framework string
+
+const (
+	// FRAMEWORK_OMZ loads plugins and themes through oh-my-zsh
+	FRAMEWORK_OMZ Framework = "omz"
+	// FRAMEWORK_NONE sources the init files of plugins and themes found while rendering
+	FRAMEWORK_NONE Framework = "none"
+	// FRAMEWORK_BUILTIN sources the init files of plugins and themes by a small loader function when zsh starts
+	FRAMEWORK_BUILTIN Framework = "builtin"
+)
+
+var Frameworks = []Framework{FRAMEWORK_OMZ, FRAMEWORK_NONE, FRAMEWORK_BUILTIN}
+
+var ErrUnknownFramework = fmt.Errorf("unknown framework")
+
+// ParseFramework parses the framework setting; an empty setting defaults to oh-my-zsh
+func ParseFramework(framework string) (Framework, error) {
+	switch strings.ToLower(framework) {
+	case "", "omz", "oh-my-zsh":
+		return FRAMEWORK_OMZ, nil
+	case "none", "plain":
+		return FRAMEWORK_NONE, nil
+	case "builtin":
+		return FRAMEWORK_BUILTIN, nil
+	default:
+		return "", fmt.Errorf("%w %q; expected one of %v", ErrUnknownFramework, framework, Frameworks)
+	}
+}
+
+// initFileCandidates returns the file patterns a plugin or theme in dir gets initialized by, most specific first
+func initFileCandidates(dir string) []string {
+	name := filepath.Base(dir)
+	return []string{
+		filepath.Join(dir, name+".plugin.zsh"),
+		filepath.Join(dir, name+".zsh-theme"),
+		filepath.Join(dir, "init.zsh"),
+		filepath.Join(dir, name+".zsh"),
+		filepath.Join(dir, name+".sh"),
+		filepath.Join(dir, "*.plugin.zsh"),
+		filepath.Join(dir, "*.zsh-theme"),
+	}
+}
+
+// findInitFile returns the file initializing the plugin or theme in dir
+func findInitFile(dir string) (path string, ok bool) {
+	for _, pattern := range initFileCandidates(dir) {
+		matches, err := afero.Glob(factory.Default.Fs, pattern)
+		if err == nil && len(matches) > 0 {
+			return matches[0], true
+		}
+	}
+	return "", false
+}
+
+// themeLocation returns the directory of the configured theme, if it is a custom one,
+// or else the oh-my-zsh style theme file.
+func themeLocation(cfg *ConfigSpec) (dir, file string) {
+	for _, spec := range cfg.Themes {
+		if spec.Kind != PLUGIN_OMZ && (spec.ID == cfg.Theme || spec.Name == cfg.Theme) {
+			spec := spec
+			return (&Theme{ThemeSpec: &spec}).Path(), ""
+		}
+	}
+	for _, file := range []string{
+		filepath.Join(env.Themes(), cfg.Theme+".zsh-theme"),
+		filepath.Join(env.OMZ(), "themes", cfg.Theme+".zsh-theme"),
+	} {
+		if _, err := factory.Default.Fs.Stat(file); err == nil {
+			return "", file
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,246 @@
+package zsh
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl/pkg/diff"
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+// HistorySize is the number of config snapshots kept next to the config file
+var HistorySize = 20
+
+const snapshotIDLayout = "20060102T150405.000000000"
+
+var now = time.Now
+
+var ErrSnapshotNotFound = fmt.Errorf("config snapshot not found")
+
+// Snapshot is a copy of the config file taken right before it got overwritten
+type Snapshot struct {
+	ID   string
+	Time time.Time
+	Path string
+}
+
+// Summary returns the diff stats between the snapshot and the config that replaced it
+func (s *Snapshot) Summary(next string) (stats diff.Stats, err error) {
+	prev, err := normalizedConfig(s.Path)
+	if err != nil {
+		return stats, err
+	}
+	succ, err := normalizedConfig(next)
+	if err != nil {
+		return stats, err
+	}
+	return diff.Summarize(prev, succ), nil
+}
+
+func normalizedConfig(path string) (formatted string, err error) {
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Format()
+}
+
+// HistoryDir returns the directory containing the snapshots of the config file at path
+func HistoryDir(path string) string {
+	return filepath.Join(filepath.Dir(path), ".history")
+}
+
+func snapshotPrefix(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "."
+}
+
+// takeSnapshot copies the config file at path into its history and drops the oldest snapshots exceeding HistorySize
+func takeSnapshot(path string) (err error) {
+	fs := factory.Default.Fs
+	data, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := HistoryDir(path)
+	if err = fs.MkdirAll(dir, configDirPerm); err != nil {
+		return err
+	}
+	name := snapshotPrefix(path) + now().UTC().Format(snapshotIDLayout) + filepath.Ext(path)
+	if err = afero.WriteFile(fs, filepath.Join(dir, name), data, configFilePerm); err != nil {
+		return err
+	}
+
+	snapshots, err := History(path)
+	if err != nil {
+		return err
+	}
+	for i := HistorySize; i < len(snapshots); i++ {
+		if err = fs.Remove(snapshots[i].Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History lists the snapshots of the config file at path, newest first
+func History(path string) (snapshots []Snapshot, err error) {
+	dir := HistoryDir(path)
+	entries, err := afero.ReadDir(factory.Default.Fs, dir)
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := snapshotPrefix(path)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, prefix), filepath.Ext(name))
+		t, err := time.Parse(snapshotIDLayout, id)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{ID: id, Time: t, Path: filepath.Join(dir, name)})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// FindSnapshot returns the snapshot of the config file at path whose ID starts with id
+func FindSnapshot(path, id string) (snapshot *Snapshot, err error) {
+	snapshots, err := History(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Snapshot
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, id) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("snapshot id %s is ambiguous and matches %d snapshots", id, len(matches))
+	}
+}
+
+// Restore replaces the current config with the snapshot identified by id.
+// The replaced config is kept as snapshot itself, so that a restore can be undone as well.
+func Restore(id string) (snapshot *Snapshot, err error) {
+	path := ConfigFile()
+	if snapshot, err = FindSnapshot(path, id); err != nil {
+		return nil, err
+	}
+	cfg, err := LoadFromPath(snapshot.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.origin = nil
+	return snapshot, SaveToPath(cfg, path)
+}
+
+// Undo restores the most recent snapshot of the current config.
+// Undoing again walks further back in the history: as long as the config did not change since the last undo,
+// the snapshot preceding the one restored last gets restored without taking another snapshot.
+func Undo() (snapshot *Snapshot, err error) {
+	path := ConfigFile()
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snapshots, err := History(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("%w: the config has no history", ErrSnapshotNotFound)
+	}
+
+	undone, err := undoCursor(path)
+	if err != nil {
+		return nil, err
+	}
+	next, snapshotCurrent := 0, true
+	if undone != "" {
+		for i, s := range snapshots {
+			if s.ID == undone {
+				next, snapshotCurrent = i+1, false
+				break
+			}
+		}
+		if next == len(snapshots) {
+			return nil, fmt.Errorf("%w: nothing left to undo before snapshot %s", ErrSnapshotNotFound, undone)
+		}
+	}
+
+	snapshot = &snapshots[next]
+	cfg, err := LoadFromPath(snapshot.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.origin = nil
+	if err = writeConfig(cfg, path, snapshotCurrent); err != nil {
+		return nil, err
+	}
+	return snapshot, setUndoCursor(path, snapshot.ID)
+}
+
+func undoCursorPath(path string) string {
+	return filepath.Join(HistoryDir(path), snapshotPrefix(path)+"undo")
+}
+
+// undoCursor returns the id of the snapshot restored by the last undo,
+// or an empty string when the config changed since then
+func undoCursor(path string) (id string, err error) {
+	fs := factory.Default.Fs
+	cursor, err := readFileIfExists(fs, undoCursorPath(path))
+	if err != nil || len(cursor) == 0 {
+		return "", err
+	}
+	current, err := readFileIfExists(fs, path)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(cursor))
+	if len(fields) != 2 || fields[1] != fmt.Sprintf("%x", sha256.Sum256(current)) {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// setUndoCursor records the id of the snapshot restored by an undo together with the checksum of the restored config
+func setUndoCursor(path, id string) (err error) {
+	fs := factory.Default.Fs
+	current, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return err
+	}
+	cursor := fmt.Sprintf("%s %x\n", id, sha256.Sum256(current))
+	return afero.WriteFile(fs, undoCursorPath(path), []byte(cursor), configFilePerm)
+}
@@ -0,0 +1,106 @@
+package zsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func withMemFs(t *testing.T, configFile string) {
+	fs := factory.Default.Fs
+	factory.Default.Fs = afero.NewMemMapFs()
+	env.Overrides.ConfigFile = configFile
+	t.Cleanup(func() {
+		factory.Default.Fs = fs
+		env.ClearOverrides()
+	})
+}
+
+func withClock(t *testing.T) {
+	clock := time.Date(2021, 12, 24, 18, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestSave_History(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	withClock(t)
+
+	historySize := HistorySize
+	HistorySize = 2
+	defer func() { HistorySize = historySize }()
+
+	for _, theme := range []string{"one", "two", "two", "three", "four"} {
+		assert.NoError(t, Save(&ConfigSpec{Theme: theme}))
+	}
+
+	snapshots, err := History(ConfigFile())
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, HistorySize)
+	assert.Equal(t, "20211224T180003.000000000", snapshots[0].ID)
+	assert.Equal(t, "three", MustLoadFromPath(snapshots[0].Path).Theme)
+	assert.Equal(t, "two", MustLoadFromPath(snapshots[1].Path).Theme)
+
+	stats, err := snapshots[0].Summary(ConfigFile())
+	assert.NoError(t, err)
+	assert.Equal(t, "+1 -1", stats.String())
+}
+
+func TestRestore(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	withClock(t)
+
+	assert.NoError(t, Save(&ConfigSpec{Theme: "one"}))
+	assert.NoError(t, Save(&ConfigSpec{Theme: "two"}))
+
+	snapshot, err := Undo()
+	assert.NoError(t, err)
+	assert.Equal(t, "20211224T180001.000000000", snapshot.ID)
+	assert.Equal(t, "one", MustLoad().Theme)
+
+	_, err = Restore("2021")
+	assert.Error(t, err, "ambiguous snapshot ids are rejected")
+
+	_, err = Restore("1999")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestUndo_WalksBack(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	withClock(t)
+
+	for _, theme := range []string{"one", "two", "three"} {
+		assert.NoError(t, Save(&ConfigSpec{Theme: theme}))
+	}
+
+	_, err := Undo()
+	assert.NoError(t, err)
+	assert.Equal(t, "two", MustLoad().Theme)
+
+	_, err = Undo()
+	assert.NoError(t, err)
+	assert.Equal(t, "one", MustLoad().Theme, "undoing again walks back instead of reverting the undo")
+
+	_, err = Undo()
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+	assert.Equal(t, "one", MustLoad().Theme)
+
+	snapshots, err := History(ConfigFile())
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 3)
+	assert.Equal(t, "three", MustLoadFromPath(snapshots[0].Path).Theme, "the config replaced by the first undo is kept")
+
+	assert.NoError(t, Save(&ConfigSpec{Theme: "four"}))
+	_, err = Undo()
+	assert.NoError(t, err)
+	assert.Equal(t, "one", MustLoad().Theme, "a change resets the undo")
+}
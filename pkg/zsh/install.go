@@ -3,6 +3,7 @@ package zsh
 import (
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/ahmetb/go-linq"
 	"github.com/rs/zerolog/log"
@@ -10,28 +11,88 @@ import (
 	"github.com/alex-held/dfctl-kit/pkg/env"
 )
 
+// InstallConcurrency is the number of plugins and themes installed at the same time
+var InstallConcurrency = 4
+
+// InstallState is the state of an installable passed to an InstallProgressFn
+type InstallState int
+
+const (
+	InstallPending InstallState = iota
+	InstallRunning
+	InstallDone
+)
+
+// InstallProgressFn gets called whenever the state of an installable changes; the result is set once it is done.
+// Calls are serialized, so that it does not need to be safe for concurrent use.
+type InstallProgressFn func(installable Installable, state InstallState, result InstallResult)
+
 func InstallThemes(cfg *ConfigSpec) (installed map[Theme]InstallResult) {
+	var themes []Installable
+	for i := range cfg.Themes {
+		themes = append(themes, &Theme{ThemeSpec: &cfg.Themes[i]})
+	}
+
 	installed = map[Theme]InstallResult{}
-	for _, theme := range cfg.Themes {
-		t := Theme{ThemeSpec: &theme}
-		installed[t] = t.Install()
+	for theme, result := range Install(themes...) {
+		installed[*theme.(*Theme)] = result
 	}
 	return installed
 }
 
 func Install(installables ...Installable) (results map[Installable]InstallResult) {
+	return InstallConcurrently(InstallConcurrency, nil, installables...)
+}
+
+// InstallConcurrently installs the installables using a pool of workers and reports their progress to the optional progress fn
+func InstallConcurrently(workers int, progress InstallProgressFn, installables ...Installable) (results map[Installable]InstallResult) {
 	results = map[Installable]InstallResult{}
+	mu := sync.Mutex{}
+	report := func(installable Installable, state InstallState, result InstallResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if state == InstallDone {
+			results[installable] = result
+		}
+		if progress != nil {
+			progress(installable, state, result)
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan Installable, len(installables))
 	for _, installable := range installables {
-		results[installable] = installable.Install()
+		report(installable, InstallPending, InstallResult{})
+		jobs <- installable
+	}
+	close(jobs)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w < len(installables); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for installable := range jobs {
+				report(installable, InstallRunning, InstallResult{})
+				report(installable, InstallDone, installable.Install())
+			}
+		}()
 	}
+	wg.Wait()
 	return results
 }
 
 func InstallPlugins(cfg *ConfigSpec) (results map[Plugin]InstallResult) {
+	var plugins []Installable
+	for i := range cfg.Plugins.Custom {
+		plugins = append(plugins, PluginFromSpec(&cfg.Plugins.Custom[i]))
+	}
+
 	results = map[Plugin]InstallResult{}
-	for _, plugin := range cfg.Plugins.Custom {
-		p := PluginFromSpec(&plugin)
-		results[*p] = p.Install()
+	for plugin, result := range Install(plugins...) {
+		results[*plugin.(*Plugin)] = result
 	}
 	return results
 }
@@ -45,7 +106,7 @@ func QueryInstallable() (query linq.Query) {
 	return linq.
 		From(cfg.Themes).
 		SelectT(func(theme ThemeSpec) Installable {
-			return &Theme{&theme}
+			return &Theme{ThemeSpec: &theme}
 		}).
 		Concat(linq.
 			From(cfg.Plugins.Custom).
@@ -0,0 +1,62 @@
+package zsh
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeInstallable struct {
+	Plugin
+	running, peak *int32
+	err           error
+}
+
+func (f *fakeInstallable) Install() InstallResult {
+	n := atomic.AddInt32(f.running, 1)
+	defer atomic.AddInt32(f.running, -1)
+	for {
+		peak := atomic.LoadInt32(f.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(f.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return InstallResult{Installed: f.err == nil, Err: f.err}
+}
+
+func TestInstallConcurrently(t *testing.T) {
+	var running, peak int32
+	var installables []Installable
+	for i := 0; i < 10; i++ {
+		fake := &fakeInstallable{Plugin: Plugin{ID: fmt.Sprintf("plugin-%d", i)}, running: &running, peak: &peak}
+		if i == 3 {
+			fake.err = fmt.Errorf("clone failed")
+		}
+		installables = append(installables, fake)
+	}
+
+	states := map[string][]InstallState{}
+	mu := sync.Mutex{}
+	results := InstallConcurrently(3, func(i Installable, state InstallState, result InstallResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		states[i.Id()] = append(states[i.Id()], state)
+	}, installables...)
+
+	assert.Len(t, results, 10)
+	assert.LessOrEqual(t, peak, int32(3))
+	assert.Greater(t, peak, int32(1), "installs run concurrently")
+	for _, installable := range installables {
+		assert.Equal(t, []InstallState{InstallPending, InstallRunning, InstallDone}, states[installable.Id()])
+		if installable.Id() == "plugin-3" {
+			assert.EqualError(t, results[installable].Err, "clone failed")
+		} else {
+			assert.True(t, results[installable].Installed)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package zsh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadMode selects when a plugin gets loaded
+type LoadMode string
+
+const (
+	// LOAD_EAGER loads the plugin while zsh starts
+	LOAD_EAGER LoadMode = "eager"
+	// LOAD_DEFERRED loads the plugin once the first prompt is shown, like zsh-defer
+	LOAD_DEFERRED LoadMode = "deferred"
+	// loadOnCommand prefixes the comma separated commands which load the plugin the first time one of them runs
+	loadOnCommand = "on-command:"
+)
+
+var ErrUnknownLoadMode = fmt.Errorf("unknown load mode")
+
+var commandName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*$`)
+
+// ParseLoadMode parses the load setting of a plugin; an empty setting defaults to eager
+func ParseLoadMode(mode string) (LoadMode, error) {
+	switch {
+	case mode == "" || mode == string(LOAD_EAGER):
+		return LOAD_EAGER, nil
+	case mode == string(LOAD_DEFERRED):
+		return LOAD_DEFERRED, nil
+	case strings.HasPrefix(mode, loadOnCommand):
+		commands := strings.Split(strings.TrimPrefix(mode, loadOnCommand), ",")
+		for i, command := range commands {
+			commands[i] = strings.TrimSpace(command)
+			if !commandName.MatchString(commands[i]) {
+				return "", fmt.Errorf("%w %q; invalid command %q", ErrUnknownLoadMode, mode, commands[i])
+			}
+		}
+		return LoadOnCommand(commands...), nil
+	default:
+		return "", fmt.Errorf("%w %q; expected %s, %s or %s<cmd>[,<cmd>...]", ErrUnknownLoadMode, mode, LOAD_EAGER, LOAD_DEFERRED, loadOnCommand)
+	}
+}
+
+// LoadOnCommand returns the load mode which loads a plugin the first time one of commands runs
+func LoadOnCommand(commands ...string) LoadMode {
+	return LoadMode(loadOnCommand + strings.Join(commands, ","))
+}
+
+// IsEager reports whether the plugin gets loaded while zsh starts
+func (m LoadMode) IsEager() bool {
+	return m == "" || m == LOAD_EAGER
+}
+
+// Commands returns the commands which load the plugin, if it is loaded on command
+func (m LoadMode) Commands() []string {
+	if !strings.HasPrefix(string(m), loadOnCommand) {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(string(m), loadOnCommand), ",")
+}
+
+func (m *LoadMode) UnmarshalYAML(value *yaml.Node) error {
+	var mode string
+	if err := value.Decode(&mode); err != nil {
+		return err
+	}
+	parsed, err := ParseLoadMode(mode)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*m = parsed
+	return nil
+}
+
+// IsZero omits eager loading, the default, when marshalling
+func (m LoadMode) IsZero() bool {
+	return m.IsEager()
+}
@@ -0,0 +1,103 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestParseLoadMode(t *testing.T) {
+	for mode, expected := range map[string]LoadMode{
+		"":                         LOAD_EAGER,
+		"eager":                    LOAD_EAGER,
+		"deferred":                 LOAD_DEFERRED,
+		"on-command:nvm":           LoadOnCommand("nvm"),
+		"on-command:nvm, node,npx": LoadOnCommand("nvm", "node", "npx"),
+	} {
+		parsed, err := ParseLoadMode(mode)
+		assert.NoError(t, err, mode)
+		assert.Equal(t, expected, parsed, mode)
+	}
+	for _, mode := range []string{"lazy", "on-command:", "on-command:nvm,", "on-command:$(rm -rf ~)"} {
+		_, err := ParseLoadMode(mode)
+		assert.ErrorIs(t, err, ErrUnknownLoadMode, mode)
+	}
+
+	assert.Equal(t, []string{"nvm", "node"}, LoadOnCommand("nvm", "node").Commands())
+	assert.Empty(t, LOAD_DEFERRED.Commands())
+}
+
+func TestOMZPluginsList_YAML(t *testing.T) {
+	var plugins OMZPluginsList
+	require.NoError(t, yaml.Unmarshal([]byte("- git\n- id: nvm\n  load: on-command:nvm,node\n- id: docker\n"), &plugins))
+	assert.Equal(t, OMZPluginsList{{ID: "git"}, {ID: "nvm", Load: LoadOnCommand("nvm", "node")}, {ID: "docker"}}, plugins)
+
+	data, err := yaml.Marshal(plugins)
+	assert.NoError(t, err)
+	assert.Equal(t, "- git\n- id: nvm\n  load: on-command:nvm,node\n- docker\n", string(data))
+
+	err = yaml.Unmarshal([]byte("- id: nvm\n  load: lazy\n"), &plugins)
+	assert.ErrorIs(t, err, ErrUnknownLoadMode)
+}
+
+func TestRender_LoadModes(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	for _, file := range []string{
+		filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh"),
+		filepath.Join(env.OMZ(), "plugins", "nvm", "nvm.plugin.zsh"),
+		filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh"),
+		filepath.Join(env.Plugins(), "zsh-syntax-highlighting", "zsh-syntax-highlighting.plugin.zsh"),
+	} {
+		require.NoError(t, afero.WriteFile(factory.Default.Fs, file, nil, 0644))
+	}
+	cfg := &ConfigSpec{Plugins: PluginsSpec{
+		OMZ: OMZPluginsList{{ID: "git"}, {ID: "nvm", Load: LoadOnCommand("nvm", "node")}},
+		Custom: PluginsList{
+			{ID: "zsh-autosuggestions", Name: "zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true, Load: LOAD_DEFERRED},
+			{ID: "zsh-syntax-highlighting", Name: "zsh-syntax-highlighting", Kind: PLUGIN_GITHUB, Enabled: true, Load: LOAD_DEFERRED},
+			{ID: "missing", Name: "missing", Kind: PLUGIN_GITHUB, Enabled: true, Load: LOAD_DEFERRED},
+		},
+	}}
+	nvm := fmt.Sprintf("source %q", filepath.Join(env.OMZ(), "plugins", "nvm", "nvm.plugin.zsh"))
+	lazy := []string{
+		"nvm() {\n\tunfunction nvm node\n\t" + nvm + "\n\tnvm \"$@\"\n}",
+		"node() {\n\tunfunction nvm node\n\t" + nvm + "\n\tnode \"$@\"\n}",
+		"_dfctl_deferred=(",
+		fmt.Sprintf("%q", filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh")),
+		fmt.Sprintf("%q", filepath.Join(env.Plugins(), "zsh-syntax-highlighting", "zsh-syntax-highlighting.plugin.zsh")),
+		"zle -F $_dfctl_deferred_fd _dfctl_load_deferred",
+	}
+
+	t.Run("omz", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_OMZ
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered, append([]string{
+			"fpath+=(",
+			fmt.Sprintf("%q", filepath.Join(env.OMZ(), "plugins", "nvm")),
+			"plugins=(\n\t\tgit\n)",
+			"source $ZSH/oh-my-zsh.sh",
+		}, lazy...)...)
+		assert.NotContains(t, rendered, "missing.plugin.zsh", "plugins without init file are skipped")
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_BUILTIN
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered, append([]string{
+			fmt.Sprintf("_dfctl_load %q", filepath.Join(env.OMZ(), "plugins", "git")),
+			"unfunction _dfctl_load",
+		}, lazy...)...)
+		assert.NotContains(t, rendered, fmt.Sprintf("_dfctl_load %q", filepath.Join(env.OMZ(), "plugins", "nvm")))
+	})
+}
@@ -0,0 +1,78 @@
+package zsh
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+// lockFileName is the advisory lock file next to the config, named to not be confused with the dfctl.lock lockfile
+const lockFileName = ".dfctl-config.flock"
+
+// processLock serializes config mutations within the process; the lock file serializes them across processes
+var processLock sync.Mutex
+
+// lockConfig acquires an exclusive advisory lock guarding the config files in the directory of path.
+// The returned unlock func must be called to release the lock.
+func lockConfig(path string) (unlock func(), err error) {
+	processLock.Lock()
+	if _, ok := factory.Default.Fs.(*afero.OsFs); !ok {
+		return processLock.Unlock, nil
+	}
+
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, configDirPerm); err != nil {
+		processLock.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, configFilePerm)
+	if err != nil {
+		processLock.Unlock()
+		return nil, err
+	}
+	if err = flock(file); err != nil {
+		_ = file.Close()
+		processLock.Unlock()
+		return nil, err
+	}
+
+	return func() {
+		_ = funlock(file)
+		_ = file.Close()
+		processLock.Unlock()
+	}, nil
+}
+
+// writeFileAtomic writes data into a temporary file next to path and renames it to path,
+// so that readers either see the previous or the new content but never a partially written file.
+func writeFileAtomic(fs afero.Fs, path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := afero.TempFile(fs, filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = fs.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = fs.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return fs.Rename(tmp.Name(), path)
+}
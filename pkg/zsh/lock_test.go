@@ -0,0 +1,98 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestUpdate_Concurrent(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	assert.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, Update(func(cfg *ConfigSpec) error {
+				cfg.Aliases.Set(fmt.Sprintf("a%d", i), "true")
+				return nil
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, MustLoad().Aliases, 25)
+}
+
+func TestUpdateLockfile_Concurrent(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, UpdateLockfile(func(lock *Lockfile) error {
+				if lock.Plugins == nil {
+					lock.Plugins = map[string]LockEntry{}
+				}
+				lock.Plugins[fmt.Sprintf("p%d", i)] = LockEntry{Repo: "owner/repo", Kind: PLUGIN_GITHUB, Commit: "abc"}
+				return nil
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	lock, err := LoadLockfile()
+	assert.NoError(t, err)
+	assert.Len(t, lock.Plugins, 25)
+}
+
+func TestSave_DetectsExternalModification(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	assert.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	cfg := MustLoad()
+	assert.NoError(t, afero.WriteFile(factory.Default.Fs, ConfigFile(), []byte("theme: agnoster\n"), 0644))
+
+	cfg.Theme = "robbyrussell"
+	assert.ErrorIs(t, Save(cfg), ErrConfigModified)
+	assert.Equal(t, "agnoster", MustLoad().Theme)
+
+	edited, err := Parse([]byte("theme: robbyrussell\n"), ".yaml")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, Save(edited.WithOriginOf(cfg)), ErrConfigModified, "edited copies keep the origin of the loaded config")
+	assert.Equal(t, "agnoster", MustLoad().Theme)
+}
+
+func TestSave_Permissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dfctl", "dfctl.yaml")
+	env.Overrides.ConfigFile = path
+	defer env.ClearOverrides()
+
+	cfg := &ConfigSpec{Theme: "simple"}
+	assert.NoError(t, Save(cfg))
+	cfg.Theme = "agnoster"
+	assert.NoError(t, Save(cfg), "saving the same config twice is no external modification")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, configFilePerm, info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".tmp-", "temporary files are renamed or removed")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package zsh
+
+import (
+	"os"
+)
+
+// advisory file locks are not supported on windows; mutations are only serialized within the process
+func flock(*os.File) error { return nil }
+
+func funlock(*os.File) error { return nil }
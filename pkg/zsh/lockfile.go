@@ -0,0 +1,171 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+var ErrNotLocked = fmt.Errorf("not in the lockfile")
+var ErrLockOutdated = fmt.Errorf("lockfile is out of date")
+
+// LockEntry records the commit a plugin or theme got installed at, together with the spec it was resolved from
+type LockEntry struct {
+	Repo   string   `yaml:"repo"`
+	Kind   RepoKind `yaml:"kind"`
+	Ref    string   `yaml:"ref,omitempty"`
+	Commit string   `yaml:"commit"`
+}
+
+// Lockfile records the exact commits of the installed plugins and themes, keyed by their ids,
+// so that installing the same config with --frozen reproduces them on another machine.
+// Plugins and themes bundled with oh-my-zsh are not locked.
+type Lockfile struct {
+	Plugins map[string]LockEntry `yaml:"plugins,omitempty"`
+	Themes  map[string]LockEntry `yaml:"themes,omitempty"`
+}
+
+// LockfilePath returns the path of the dfctl.lock next to the config file
+func LockfilePath() string {
+	return filepath.Join(filepath.Dir(ConfigFile()), "dfctl.lock")
+}
+
+// LoadLockfile loads the lockfile; a missing lockfile is empty
+func LoadLockfile() (lock *Lockfile, err error) {
+	lock = &Lockfile{}
+	data, err := readFileIfExists(factory.Default.Fs, LockfilePath())
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", LockfilePath(), err)
+	}
+	return lock, nil
+}
+
+// UpdateLockfile loads the lockfile, applies fn and saves the result while holding the config lock,
+// so that concurrent dfctl processes don't overwrite each others entries
+func UpdateLockfile(fn func(lock *Lockfile) error) (err error) {
+	path := LockfilePath()
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lock, err := LoadLockfile()
+	if err != nil {
+		return err
+	}
+	if err = fn(lock); err != nil {
+		return err
+	}
+	return lock.saveToPath(path)
+}
+
+// Save writes the lockfile while holding the config lock
+func (lock *Lockfile) Save() (err error) {
+	path := LockfilePath()
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return lock.saveToPath(path)
+}
+
+// saveToPath writes the lockfile to path; the caller must hold the config lock
+func (lock *Lockfile) saveToPath(path string) (err error) {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	if err = factory.Default.Fs.MkdirAll(filepath.Dir(path), configDirPerm); err != nil {
+		return err
+	}
+	return writeFileAtomic(factory.Default.Fs, path, data, configFilePerm)
+}
+
+// Record locks the commit currently checked out for the installed plugin or theme
+func (lock *Lockfile) Record(i Installable) (err error) {
+	entries, entry, ok := lock.entryFor(i)
+	if !ok {
+		return nil
+	}
+	if entry.Commit, err = HeadCommit(i.Path()); err != nil {
+		return fmt.Errorf("unable to lock %s: %w", i.Id(), err)
+	}
+	if *entries == nil {
+		*entries = map[string]LockEntry{}
+	}
+	(*entries)[i.Id()] = entry
+	return nil
+}
+
+// Pin sets the locked commit as the commit to install the plugin or theme at.
+// It fails when the plugin or theme is not locked or its repo or ref changed since it got locked.
+func (lock *Lockfile) Pin(i Installable) (commit string, err error) {
+	entries, entry, ok := lock.entryFor(i)
+	if !ok {
+		return "", nil
+	}
+	locked, ok := (*entries)[i.Id()]
+	switch {
+	case !ok:
+		return "", fmt.Errorf("%s %w", i.Id(), ErrNotLocked)
+	case locked.Repo != entry.Repo || locked.Kind != entry.Kind || locked.Ref != entry.Ref:
+		return "", fmt.Errorf("%w: %s changed since it got locked", ErrLockOutdated, i.Id())
+	}
+
+	switch it := i.(type) {
+	case *Plugin:
+		it.Commit = locked.Commit
+	case *Theme:
+		it.Commit = locked.Commit
+	}
+	return locked.Commit, nil
+}
+
+// Prune removes the entries of plugins and themes which are no longer part of installables
+func (lock *Lockfile) Prune(installables []Installable) {
+	plugins, themes := map[string]bool{}, map[string]bool{}
+	for _, i := range installables {
+		switch i.(type) {
+		case *Plugin:
+			plugins[i.Id()] = true
+		case *Theme:
+			themes[i.Id()] = true
+		}
+	}
+	for id := range lock.Plugins {
+		if !plugins[id] {
+			delete(lock.Plugins, id)
+		}
+	}
+	for id := range lock.Themes {
+		if !themes[id] {
+			delete(lock.Themes, id)
+		}
+	}
+}
+
+// entryFor returns the entries i belongs to and its unlocked entry; ok is false for plugins and themes bundled with oh-my-zsh
+// and for plugins which are not git clones
+func (lock *Lockfile) entryFor(i Installable) (entries *map[string]LockEntry, entry LockEntry, ok bool) {
+	switch it := i.(type) {
+	case *Plugin:
+		if !it.Kind.IsGit() {
+			return nil, entry, false
+		}
+		return &lock.Plugins, LockEntry{Repo: it.Repo, Kind: it.Kind, Ref: it.Ref}, true
+	case *Theme:
+		if it.Kind == PLUGIN_OMZ {
+			return nil, entry, false
+		}
+		return &lock.Themes, LockEntry{Repo: it.Repo, Kind: it.Kind, Ref: it.Ref}, true
+	}
+	return nil, entry, false
+}
@@ -0,0 +1,196 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func TestCloneAt(t *testing.T) {
+	dir := t.TempDir()
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, "plugin.zsh", "v1")
+	_, err = origin.CreateTag("v1", first, nil)
+	require.NoError(t, err)
+	latest := commitFile(t, origin, "plugin.zsh", "v2")
+
+	for name, tc := range map[string]struct {
+		ref, commit string
+		expected    string
+	}{
+		"default branch": {expected: latest.String()},
+		"tag":            {ref: "v1", expected: first.String()},
+		"short commit":   {ref: first.String()[:7], expected: first.String()},
+		"commit":         {ref: "v1", commit: latest.String(), expected: latest.String()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			assert.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), ref: tc.ref, commit: tc.commit, CloneSpec: DefaultClone}))
+			head, err := HeadCommit(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, head)
+		})
+	}
+
+	path := filepath.Join(dir, "unknown")
+	assert.ErrorIs(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), ref: "v9", CloneSpec: DefaultClone}), ErrUnknownRef)
+	assert.NoDirExists(t, path, "partial clones are removed")
+
+	path = filepath.Join(dir, "pinned")
+	assert.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), ref: "v1", CloneSpec: DefaultClone}))
+	result := UpdateRepository(path, false)
+	assert.True(t, result.Skipped)
+	assert.ErrorIs(t, result.Err, ErrPinned)
+}
+
+func TestCloneAt_Branch(t *testing.T) {
+	dir := t.TempDir()
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	commitFile(t, origin, "plugin.zsh", "v1")
+	worktree, err := origin.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: "refs/heads/dev", Create: true}))
+	dev := commitFile(t, origin, "plugin.zsh", "dev")
+
+	path := filepath.Join(dir, "plugin")
+	assert.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), ref: "dev", CloneSpec: DefaultClone}))
+	head, err := HeadCommit(path)
+	assert.NoError(t, err)
+	assert.Equal(t, dev.String(), head)
+
+	next := commitFile(t, origin, "plugin.zsh", "dev 2")
+	result := UpdateRepository(path, false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, next, result.To, "the branch tracks the remote branch")
+}
+
+func TestCloneAt_Shallow(t *testing.T) {
+	dir := t.TempDir()
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, "plugin.zsh", "v1")
+	for i := 0; i < 60; i++ {
+		commitFile(t, origin, "plugin.zsh", fmt.Sprintf("v%d", i+2))
+	}
+	latest := commitFile(t, origin, "plugin.zsh", "latest")
+	worktree, err := origin.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: "refs/heads/dev", Create: true}))
+	dev := commitFile(t, origin, "plugin.zsh", "dev")
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: "refs/heads/master"}))
+
+	path := filepath.Join(dir, "shallow")
+	require.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), CloneSpec: DefaultClone}))
+	repo, err := git.PlainOpen(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countCommits(t, repo), "only the latest commit is cloned")
+	_, err = repo.Reference(plumbing.NewRemoteReferenceName("origin", "dev"), true)
+	assert.Error(t, err, "other branches are not cloned")
+
+	changed, err := Checkout(path, first.String())
+	assert.NoError(t, err, "older commits are fetched by deepening the history")
+	assert.True(t, changed)
+	changed, err = Checkout(path, dev.String())
+	assert.NoError(t, err, "commits of other branches are fetched at last")
+	assert.True(t, changed)
+
+	path = filepath.Join(dir, "full")
+	full := CloneSpec{Depth: intptr(0), SingleBranch: boolptr(false)}
+	require.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), CloneSpec: full}))
+	repo, err = git.PlainOpen(path)
+	require.NoError(t, err)
+	assert.Equal(t, 62, countCommits(t, repo))
+	head, err := HeadCommit(path)
+	assert.NoError(t, err)
+	assert.Equal(t, latest.String(), head)
+}
+
+func countCommits(t *testing.T, repo *git.Repository) (count int) {
+	head, err := repo.Head()
+	require.NoError(t, err)
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	require.NoError(t, err)
+	_ = commits.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	return count
+}
+
+func TestCloneSpec_Or(t *testing.T) {
+	spec := CloneSpec{Depth: intptr(10)}.Or(CloneSpec{SingleBranch: boolptr(false)}).Or(DefaultClone)
+	assert.Equal(t, CloneSpec{Depth: intptr(10), SingleBranch: boolptr(false)}, spec)
+	assert.Equal(t, DefaultClone, CloneSpec{}.Or(DefaultClone))
+}
+
+func TestLockfile(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+	require.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, "plugin.zsh", "v1")
+	locked := commitFile(t, origin, "plugin.zsh", "v2")
+
+	spec := PluginSpec{ID: "plugin", Name: "plugin", Repo: filepath.Join(dir, "origin"), Kind: PLUGIN_GIT, Enabled: true}
+	plugin := PluginFromSpec(&spec)
+	require.NoError(t, plugin.Install().Err)
+
+	lock, err := LoadLockfile()
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Record(plugin))
+	assert.NoError(t, lock.Record(&Plugin{ID: "git", Name: "git", Kind: PLUGIN_OMZ}), "omz plugins are not locked")
+	assert.NoError(t, lock.Save())
+
+	lock, err = LoadLockfile()
+	assert.NoError(t, err)
+	assert.Equal(t, &Lockfile{Plugins: map[string]LockEntry{
+		"plugin": {Repo: spec.Repo, Kind: PLUGIN_GIT, Commit: locked.String()},
+	}}, lock)
+
+	// a fresh install with the lockfile reproduces the locked commit
+	commitFile(t, origin, "plugin.zsh", "v3")
+	require.NoError(t, os.RemoveAll(plugin.Path()))
+	plugin = PluginFromSpec(&spec)
+	commit, err := lock.Pin(plugin)
+	assert.NoError(t, err)
+	assert.Equal(t, locked.String(), commit)
+	assert.NoError(t, plugin.Install().Err)
+	head, err := HeadCommit(plugin.Path())
+	assert.NoError(t, err)
+	assert.Equal(t, locked.String(), head)
+
+	latest := commitFile(t, origin, "plugin.zsh", "v4")
+	result := UpdateRepository(plugin.Path(), false)
+	assert.NoError(t, result.Err, "checking out a locked commit keeps following the branch")
+	assert.Equal(t, latest, result.To)
+
+	changed, err := Checkout(plugin.Path(), first.String())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = Checkout(plugin.Path(), first.String())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	_, err = lock.Pin(&Plugin{ID: "plugin", Repo: spec.Repo, Kind: PLUGIN_GIT, Ref: "v1"})
+	assert.ErrorIs(t, err, ErrLockOutdated)
+	_, err = lock.Pin(&Plugin{ID: "other", Repo: spec.Repo, Kind: PLUGIN_GIT})
+	assert.ErrorIs(t, err, ErrNotLocked)
+
+	lock.Prune([]Installable{&Plugin{ID: "other", Kind: PLUGIN_GIT}})
+	assert.Empty(t, lock.Plugins)
+}
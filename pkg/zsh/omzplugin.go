@@ -5,11 +5,40 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/alex-held/dfctl-kit/pkg/env"
 )
 
 type OMZPlugin struct {
-	ID string
+	ID   string
+	Load LoadMode
+}
+
+type plainOMZPlugin struct {
+	ID   string   `yaml:"id"`
+	Load LoadMode `yaml:"load,omitempty"`
+}
+
+// UnmarshalYAML reads the id of an eagerly loaded plugin, or a mapping with its id and load mode
+func (p *OMZPlugin) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = OMZPlugin{ID: node.Value}
+		return nil
+	}
+	plain := plainOMZPlugin{}
+	if err := node.Decode(&plain); err != nil {
+		return err
+	}
+	*p = OMZPlugin{ID: plain.ID, Load: plain.Load}
+	return nil
+}
+
+func (p OMZPlugin) MarshalYAML() (interface{}, error) {
+	if p.Load.IsEager() {
+		return p.ID, nil
+	}
+	return plainOMZPlugin{ID: p.ID, Load: p.Load}, nil
 }
 
 func (p OMZPlugin) MarshalText() (text []byte, err error) {
@@ -36,14 +65,10 @@ func (p OMZPlugin) UnmarshalTOML(i interface{}) error {
 }
 
 func (p *OMZPlugin) SetEnabled(enable bool) error {
-	cfg, err := Load()
-	if err != nil {
-		return err
-	}
-
-	cfg.Plugins.OMZ.Enable(p.ID, enable)
-	err = Save(cfg)
-	return err
+	return Update(func(cfg *ConfigSpec) error {
+		cfg.Plugins.OMZ.Enable(p.ID, enable)
+		return nil
+	})
 }
 
 func (p *OMZPlugin) IsEnabled() bool {
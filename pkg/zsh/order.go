@@ -0,0 +1,154 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+var ErrPluginCycle = fmt.Errorf("plugins are ordered in a cycle")
+
+// enabledPluginSpecs returns the plugins bundled with oh-my-zsh followed by the enabled custom plugins, in the order of cfg
+func enabledPluginSpecs(cfg *ConfigSpec) (specs []PluginSpec) {
+	for _, plugin := range cfg.Plugins.OMZ {
+		specs = append(specs, PluginSpec{ID: plugin.ID, Name: plugin.ID, Kind: PLUGIN_OMZ, Load: plugin.Load, Enabled: true})
+	}
+	for _, spec := range cfg.Plugins.Custom {
+		if spec.Enabled {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// LoadOrder returns the enabled plugins in the order they get loaded.
+//
+// Plugins keep the order of the config, plugins bundled with oh-my-zsh first, and are only moved as far as needed
+// to load them after the plugins they require or list in After and before the plugins they list in Before.
+// Plugins which are not enabled are ignored.
+func LoadOrder(cfg *ConfigSpec) (plugins []*Plugin, err error) {
+	plugins, cycle := sortPlugins(enabledPluginSpecs(cfg))
+	if len(cycle) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPluginCycle, strings.Join(cycle, ", "))
+	}
+	return plugins, nil
+}
+
+// sortPlugins sorts specs topologically, picking the first plugin of specs whose predecessors are loaded at each step.
+// cycle lists the plugins which could not be sorted.
+func sortPlugins(specs []PluginSpec) (plugins []*Plugin, cycle []string) {
+	index := map[string]int{}
+	for i := len(specs) - 1; i >= 0; i-- {
+		index[specs[i].ID] = i
+	}
+	successors, predecessors := make([][]int, len(specs)), make([]int, len(specs))
+	edge := func(from, to int) {
+		successors[from] = append(successors[from], to)
+		predecessors[to]++
+	}
+	for i, spec := range specs {
+		for _, id := range append(append([]string{}, spec.After...), spec.Requires.Plugins...) {
+			if j, ok := index[id]; ok && j != i {
+				edge(j, i)
+			}
+		}
+		for _, id := range spec.Before {
+			if j, ok := index[id]; ok && j != i {
+				edge(i, j)
+			}
+		}
+	}
+
+	loaded := make([]bool, len(specs))
+	for len(plugins) < len(specs) {
+		next := -1
+		for i := range specs {
+			if !loaded[i] && predecessors[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			for i, spec := range specs {
+				if !loaded[i] {
+					cycle = append(cycle, spec.ID)
+				}
+			}
+			return plugins, cycle
+		}
+		loaded[next] = true
+		for _, successor := range successors[next] {
+			predecessors[successor]--
+		}
+		plugins = append(plugins, PluginFromSpec(&specs[next]))
+	}
+	return plugins, nil
+}
+
+const (
+	PluginMissingPlugin     = "missing plugin"
+	PluginMissingExecutable = "missing executable"
+	PluginCycle             = "cycle"
+)
+
+// PluginIssue is a problem of an enabled plugin found by DiagnosePlugins
+type PluginIssue struct {
+	Plugin string
+	Kind   string
+	Detail string
+}
+
+// DiagnosePlugins checks the enabled plugins for required plugins which are not enabled,
+// required executables which are neither on the system $PATH nor in a directory added to it by cfg,
+// and plugins ordered in a cycle.
+func DiagnosePlugins(cfg *ConfigSpec, facts Facts) (issues []PluginIssue, err error) {
+	specs := enabledPluginSpecs(cfg)
+	if _, cycle := sortPlugins(specs); len(cycle) > 0 {
+		issues = append(issues, PluginIssue{Plugin: strings.Join(cycle, ", "), Kind: PluginCycle, Detail: "after, before and requires contradict each other"})
+	}
+
+	prepended, appended, err := cfg.Configs.Paths.Resolve(facts)
+	if err != nil {
+		return nil, err
+	}
+	dirs := append(append(prepended, filepath.SplitList(facts.Env.Get("PATH"))...), appended...)
+
+	enabled := map[string]bool{}
+	for _, spec := range specs {
+		enabled[spec.ID] = true
+	}
+	for _, spec := range specs {
+		for _, id := range spec.Requires.Plugins {
+			if !enabled[id] {
+				issues = append(issues, PluginIssue{Plugin: spec.ID, Kind: PluginMissingPlugin, Detail: id + " is not enabled"})
+			}
+		}
+		for _, name := range spec.Requires.Executables {
+			if !hasExecutable(dirs, name, facts) {
+				issues = append(issues, PluginIssue{Plugin: spec.ID, Kind: PluginMissingExecutable, Detail: name + " not found on $PATH"})
+			}
+		}
+	}
+	return issues, nil
+}
+
+// hasExecutable returns whether name is an executable in one of dirs, or at its path if it contains a slash
+func hasExecutable(dirs []string, name string, facts Facts) bool {
+	candidates := []string{expandPath(name, facts)}
+	if !strings.Contains(name, "/") {
+		candidates = nil
+		for _, dir := range dirs {
+			if dir != "" {
+				candidates = append(candidates, filepath.Join(expandPath(dir, facts), name))
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := factory.Default.Fs.Stat(candidate); err == nil && !info.IsDir() && info.Mode().Perm()&0111 != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package zsh
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestLoadOrder(t *testing.T) {
+	plugin := func(id string, fns ...func(*PluginSpec)) PluginSpec {
+		spec := PluginSpec{ID: id, Name: id, Repo: "user/" + id, Kind: PLUGIN_GITHUB, Enabled: true}
+		for _, fn := range fns {
+			fn(&spec)
+		}
+		return spec
+	}
+	after := func(ids ...string) func(*PluginSpec) { return func(s *PluginSpec) { s.After = ids } }
+	before := func(ids ...string) func(*PluginSpec) { return func(s *PluginSpec) { s.Before = ids } }
+	requires := func(ids ...string) func(*PluginSpec) { return func(s *PluginSpec) { s.Requires.Plugins = ids } }
+
+	for name, tc := range map[string]struct {
+		omz      OMZPluginsList
+		custom   PluginsList
+		expected []string
+		err      error
+	}{
+		"config order": {
+			omz:      OMZPluginsList{{ID: "git"}, {ID: "docker"}},
+			custom:   PluginsList{plugin("a"), plugin("b")},
+			expected: []string{"git", "docker", "a", "b"},
+		},
+		"after": {
+			custom:   PluginsList{plugin("highlighting", after("suggestions", "not-enabled")), plugin("suggestions"), plugin("c")},
+			expected: []string{"suggestions", "highlighting", "c"},
+		},
+		"before omz": {
+			omz:      OMZPluginsList{{ID: "git"}, {ID: "fzf"}},
+			custom:   PluginsList{plugin("early", before("fzf"))},
+			expected: []string{"git", "early", "fzf"},
+		},
+		"requires": {
+			custom:   PluginsList{plugin("fzf-tab", requires("fzf")), plugin("fzf")},
+			expected: []string{"fzf", "fzf-tab"},
+		},
+		"disabled plugins are ignored": {
+			custom: PluginsList{plugin("a", after("b")), plugin("b", func(s *PluginSpec) {
+				s.Enabled = false
+				s.After = []string{"a"}
+			})},
+			expected: []string{"a"},
+		},
+		"cycle": {
+			custom: PluginsList{plugin("a", after("b")), plugin("b", after("c")), plugin("c", requires("a")), plugin("d")},
+			err:    ErrPluginCycle,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			plugins, err := LoadOrder(&ConfigSpec{Plugins: PluginsSpec{OMZ: tc.omz, Custom: tc.custom}})
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Contains(t, err.Error(), "a, b, c")
+				return
+			}
+			assert.NoError(t, err)
+			var ids []string
+			for _, p := range plugins {
+				ids = append(ids, p.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestRender_LoadOrder(t *testing.T) {
+	cfg := &ConfigSpec{Plugins: PluginsSpec{
+		OMZ: OMZPluginsList{{ID: "git"}},
+		Custom: PluginsList{
+			{ID: "zsh-syntax-highlighting", Name: "zsh-syntax-highlighting", Repo: "zsh-users/zsh-syntax-highlighting", Kind: PLUGIN_GITHUB, Enabled: true, After: []string{"zsh-autosuggestions"}},
+			{ID: "zsh-autosuggestions", Name: "zsh-autosuggestions", Repo: "zsh-users/zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true},
+		},
+	}}
+	rendered, err := renderWithFacts(cfg, testFacts)
+	assert.NoError(t, err)
+	assertInOrder(t, rendered, "plugins=(", "\t\tgit\n", "\t\tzsh-autosuggestions\n", "\t\tzsh-syntax-highlighting\n")
+
+	cfg.Plugins.Custom[1].After = []string{"zsh-syntax-highlighting"}
+	_, err = renderWithFacts(cfg, testFacts)
+	assert.ErrorIs(t, err, ErrPluginCycle)
+}
+
+func TestDiagnosePlugins(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	fs := factory.Default.Fs
+	for path, perm := range map[string]int{
+		"/usr/bin/fzf":          0755,
+		"/usr/bin/zoxide":       0644,
+		"/Users/alex/bin/delta": 0755,
+	} {
+		require.NoError(t, afero.WriteFile(fs, path, nil, 0))
+		require.NoError(t, fs.Chmod(path, os.FileMode(perm)))
+	}
+	facts := testFacts
+	facts.Env = env.Vars{"PATH": "/usr/bin:/bin"}
+
+	cfg := &ConfigSpec{
+		Configs: ConfigsSpec{Paths: PathListOf("~/bin")},
+		Plugins: PluginsSpec{
+			OMZ: OMZPluginsList{{ID: "git"}},
+			Custom: PluginsList{
+				{ID: "fzf-tab", Enabled: true, Requires: RequiresSpec{Plugins: []string{"git", "fzf"}, Executables: []string{"fzf", "delta"}}},
+				{ID: "zoxide", Enabled: true, Requires: RequiresSpec{Executables: []string{"zoxide", "/opt/zoxide"}}},
+				{ID: "disabled", Requires: RequiresSpec{Executables: []string{"missing"}}},
+			},
+		},
+	}
+	issues, err := DiagnosePlugins(cfg, facts)
+	assert.NoError(t, err)
+	assert.Equal(t, []PluginIssue{
+		{Plugin: "fzf-tab", Kind: PluginMissingPlugin, Detail: "fzf is not enabled"},
+		{Plugin: "zoxide", Kind: PluginMissingExecutable, Detail: "zoxide not found on $PATH"},
+		{Plugin: "zoxide", Kind: PluginMissingExecutable, Detail: "/opt/zoxide not found on $PATH"},
+	}, issues)
+
+	cfg.Plugins.Custom[0].Requires = RequiresSpec{}
+	cfg.Plugins.Custom[0].After = []string{"zoxide"}
+	cfg.Plugins.Custom[1].Requires = RequiresSpec{}
+	issues, err = DiagnosePlugins(cfg, facts)
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+
+	cfg.Plugins.Custom[1].After = []string{"fzf-tab"}
+	issues, err = DiagnosePlugins(cfg, facts)
+	assert.NoError(t, err)
+	assert.Equal(t, []PluginIssue{{Plugin: "fzf-tab, zoxide", Kind: PluginCycle, Detail: "after, before and requires contradict each other"}}, issues)
+}
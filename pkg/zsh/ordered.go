@@ -0,0 +1,228 @@
+package zsh
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyValue is a single entry of KeyValues
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// KeyValues is a string map which keeps the order its entries are written in the config.
+// It is encoded as a mapping, e.g. `aliases: {k: kubectl}`.
+type KeyValues []KeyValue
+
+// KeyValuesOf creates KeyValues from a map, sorted by key
+func KeyValuesOf(m map[string]string) (kvs KeyValues) {
+	for _, key := range sortedKeys(m) {
+		kvs = append(kvs, KeyValue{Key: key, Value: m[key]})
+	}
+	return kvs
+}
+
+// Get returns the value of key
+func (kvs KeyValues) Get(key string) (value string, ok bool) {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set updates the value of key in place or appends it, if key does not exist yet
+func (kvs *KeyValues) Set(key, value string) {
+	for i, kv := range *kvs {
+		if kv.Key == key {
+			(*kvs)[i].Value = value
+			return
+		}
+	}
+	*kvs = append(*kvs, KeyValue{Key: key, Value: value})
+}
+
+// Delete removes key
+func (kvs *KeyValues) Delete(key string) {
+	for i, kv := range *kvs {
+		if kv.Key == key {
+			*kvs = append((*kvs)[:i], (*kvs)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (kvs *KeyValues) UnmarshalYAML(node *yaml.Node) error {
+	*kvs = nil
+	return decodeMapping(node, func(key string, value *yaml.Node) error {
+		kv := KeyValue{Key: key}
+		if err := value.Decode(&kv.Value); err != nil {
+			return err
+		}
+		*kvs = append(*kvs, kv)
+		return nil
+	})
+}
+
+func (kvs KeyValues) MarshalYAML() (interface{}, error) {
+	mapping := newMapping()
+	for _, kv := range kvs {
+		if err := appendMapping(mapping, kv.Key, kv.Value); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// NamedValue is a single entry of Values
+type NamedValue struct {
+	Name string
+	Value
+}
+
+// Values maps names, e.g. of environment variables, to conditional values.
+// It keeps the order its entries are written in the config.
+type Values []NamedValue
+
+// ValuesOf creates Values without conditions from the plain values, sorted by name
+func ValuesOf(values map[string]string) (result Values) {
+	for _, name := range sortedKeys(values) {
+		result = append(result, NamedValue{Name: name, Value: Value{Value: values[name]}})
+	}
+	return result
+}
+
+// Get returns the value of name
+func (values Values) Get(name string) (value Value, ok bool) {
+	for _, v := range values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// Set updates the value of name in place or appends it, if name does not exist yet
+func (values *Values) Set(name string, value Value) {
+	for i, v := range *values {
+		if v.Name == name {
+			(*values)[i].Value = value
+			return
+		}
+	}
+	*values = append(*values, NamedValue{Name: name, Value: value})
+}
+
+func (values *Values) UnmarshalYAML(node *yaml.Node) error {
+	*values = nil
+	return decodeMapping(node, func(name string, value *yaml.Node) error {
+		v := NamedValue{Name: name}
+		if err := value.Decode(&v.Value); err != nil {
+			return err
+		}
+		*values = append(*values, v)
+		return nil
+	})
+}
+
+func (values Values) MarshalYAML() (interface{}, error) {
+	mapping := newMapping()
+	for _, v := range values {
+		if err := appendMapping(mapping, v.Name, v.Value); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// Option is a single entry of Options
+type Option struct {
+	Name    string
+	Enabled bool
+}
+
+// Options maps zsh options to whether they are set or unset.
+// It keeps the order its entries are written in the config.
+type Options []Option
+
+// OptionsOf creates Options from a map, sorted by name
+func OptionsOf(m map[string]bool) (options Options) {
+	for name, enabled := range m {
+		options = append(options, Option{Name: name, Enabled: enabled})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Name < options[j].Name })
+	return options
+}
+
+// Set updates the option name in place or appends it, if name does not exist yet
+func (options *Options) Set(name string, enabled bool) {
+	for i, o := range *options {
+		if o.Name == name {
+			(*options)[i].Enabled = enabled
+			return
+		}
+	}
+	*options = append(*options, Option{Name: name, Enabled: enabled})
+}
+
+func (options *Options) UnmarshalYAML(node *yaml.Node) error {
+	*options = nil
+	return decodeMapping(node, func(name string, value *yaml.Node) error {
+		o := Option{Name: name}
+		if err := value.Decode(&o.Enabled); err != nil {
+			return err
+		}
+		*options = append(*options, o)
+		return nil
+	})
+}
+
+func (options Options) MarshalYAML() (interface{}, error) {
+	mapping := newMapping()
+	for _, o := range options {
+		if err := appendMapping(mapping, o.Name, o.Enabled); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+func decodeMapping(node *yaml.Node, fn func(key string, value *yaml.Node) error) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := fn(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+func appendMapping(mapping *yaml.Node, key string, value interface{}) error {
+	keyNode, valueNode := &yaml.Node{}, &yaml.Node{}
+	if err := keyNode.Encode(key); err != nil {
+		return err
+	}
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+	mapping.Content = append(mapping.Content, keyNode, valueNode)
+	return nil
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package zsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender_KeepsConfigOrder(t *testing.T) {
+	data := `
+exports:
+  GOPATH: ${{ home }}/go
+  GOBIN: $GOPATH/bin
+  EDITOR: vim
+aliases:
+  zshconfig: dfctl config edit
+  k: kubectl
+configs:
+  zshoptions:
+    no_beep: true
+    autocd: true
+`
+	for _, ext := range Extensions {
+		t.Run(ext, func(t *testing.T) {
+			cfg, err := Parse([]byte(data), ".yaml")
+			assert.NoError(t, err)
+
+			formatted, err := cfg.Format(func(f *ConfigFormatter) { f.ConfigFileType = ext })
+			assert.NoError(t, err)
+			cfg, err = Parse([]byte(formatted), ext)
+			assert.NoError(t, err)
+
+			rendered, err := renderWithFacts(cfg, testFacts)
+			assert.NoError(t, err)
+			assertInOrder(t, rendered,
+				`export GOPATH="/Users/alex/go"`,
+				`export GOBIN="$GOPATH/bin"`,
+				`export EDITOR="vim"`,
+				`alias zshconfig='dfctl config edit'`,
+				`alias k=kubectl`,
+				`setopt no_beep`,
+				`setopt autocd`,
+			)
+		})
+	}
+}
+
+func assertInOrder(t *testing.T, s string, parts ...string) {
+	t.Helper()
+	offset := 0
+	for _, part := range parts {
+		i := strings.Index(s[offset:], part)
+		if !assert.GreaterOrEqual(t, i, 0, "%q missing or out of order in\n%s", part, s) {
+			return
+		}
+		offset += i + len(part)
+	}
+}
+
+func TestKeyValues_Set(t *testing.T) {
+	kvs := KeyValues{{Key: "b", Value: "1"}, {Key: "a", Value: "2"}}
+	kvs.Set("b", "3")
+	kvs.Set("c", "4")
+	kvs.Delete("a")
+	assert.Equal(t, KeyValues{{Key: "b", Value: "3"}, {Key: "c", Value: "4"}}, kvs)
+
+	value, ok := kvs.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "4", value)
+}
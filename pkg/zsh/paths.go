@@ -0,0 +1,258 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+// PathPosition is where a PathEntry gets added to $PATH
+type PathPosition string
+
+const (
+	PATH_APPEND  PathPosition = "append"
+	PATH_PREPEND PathPosition = "prepend"
+)
+
+var ErrUnknownPathPosition = fmt.Errorf("unknown path position")
+var ErrPathNotFound = fmt.Errorf("path not found")
+var ErrInvalidPath = fmt.Errorf("invalid path")
+
+// ParsePathPosition parses a path position; the empty string appends
+func ParsePathPosition(position string) (PathPosition, error) {
+	switch PathPosition(strings.ToLower(position)) {
+	case "", PATH_APPEND:
+		return PATH_APPEND, nil
+	case PATH_PREPEND:
+		return PATH_PREPEND, nil
+	}
+	return "", fmt.Errorf("%w %q, expected one of %s, %s", ErrUnknownPathPosition, position, PATH_APPEND, PATH_PREPEND)
+}
+
+// PathEntry is a directory added to $PATH, which may only apply when its When condition holds.
+// Appended entries without condition are written as plain scalars:
+//
+//	configs:
+//	  paths:
+//	    - $GOBIN
+//	    - value: /opt/homebrew/bin
+//	      when: os == "darwin"
+//	      position: prepend
+type PathEntry struct {
+	Value
+	Position PathPosition
+}
+
+type plainPathEntry struct {
+	Value    string       `yaml:"value"`
+	When     string       `yaml:"when,omitempty"`
+	Position PathPosition `yaml:"position,omitempty"`
+}
+
+func (p *PathEntry) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind == yaml.ScalarNode {
+		*p = PathEntry{Value: Value{Value: node.Value}, Position: PATH_APPEND}
+		return nil
+	}
+	plain := plainPathEntry{}
+	if err = node.Decode(&plain); err != nil {
+		return err
+	}
+	position, err := ParsePathPosition(string(plain.Position))
+	if err != nil {
+		return fmt.Errorf("path %s: %w", plain.Value, err)
+	}
+	*p = PathEntry{Value: Value{Value: plain.Value, When: plain.When}, Position: position}
+	return nil
+}
+
+func (p PathEntry) MarshalYAML() (interface{}, error) {
+	if p.When == "" && !p.Prepends() {
+		return p.Value.Value, nil
+	}
+	plain := plainPathEntry{Value: p.Value.Value, When: p.When}
+	if p.Prepends() {
+		plain.Position = PATH_PREPEND
+	}
+	return plain, nil
+}
+
+// Prepends returns whether the entry gets added in front of $PATH
+func (p PathEntry) Prepends() bool {
+	return p.Position == PATH_PREPEND
+}
+
+// PathList is the ordered list of directories added to $PATH
+type PathList []PathEntry
+
+// PathListOf creates a PathList of appended entries without conditions
+func PathListOf(dirs ...string) (list PathList) {
+	for _, dir := range dirs {
+		list = append(list, PathEntry{Value: Value{Value: dir}, Position: PATH_APPEND})
+	}
+	return list
+}
+
+// Add adds entry to the end of the list; an entry with the same directory and condition is replaced
+func (list *PathList) Add(entry PathEntry) (err error) {
+	if err = validatePath(entry.Value.Value); err != nil {
+		return err
+	}
+	for i, existing := range *list {
+		if existing.Value == entry.Value {
+			(*list)[i] = entry
+			return nil
+		}
+	}
+	*list = append(*list, entry)
+	return nil
+}
+
+// Remove removes all entries of dir and returns ErrPathNotFound when there is none
+func (list *PathList) Remove(dir string) (err error) {
+	kept := PathList{}
+	for _, entry := range *list {
+		if entry.Value.Value != dir {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(*list) {
+		return fmt.Errorf("%w: %s", ErrPathNotFound, dir)
+	}
+	*list = kept
+	return nil
+}
+
+// Resolve evaluates the conditions and interpolates the directories of all entries against facts.
+// Entries whose condition does not hold are omitted.
+func (list PathList) Resolve(facts Facts) (prepended, appended []string, err error) {
+	for _, entry := range list {
+		ok, err := facts.Eval(entry.When)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid condition of %s: %w", entry.Value.Value, err)
+		}
+		if !ok {
+			continue
+		}
+		interpolated, err := facts.Interpolate(entry.Value.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value %s: %w", entry.Value.Value, err)
+		}
+		if entry.Prepends() {
+			prepended = append(prepended, interpolated)
+		} else {
+			appended = append(appended, interpolated)
+		}
+	}
+	return prepended, appended, nil
+}
+
+// validatePath rejects directories which can not be written into a $PATH entry
+func validatePath(dir string) error {
+	if strings.TrimSpace(dir) == "" || strings.ContainsAny(dir, ":\n\r\x00") {
+		return fmt.Errorf("%w %q", ErrInvalidPath, dir)
+	}
+	return nil
+}
+
+const (
+	PathMissing       = "missing"
+	PathDuplicate     = "duplicate"
+	PathShadowing     = "shadowing"
+	PathNotADirectory = "not a directory"
+)
+
+// PathIssue is a problem of a configured path found by DiagnosePaths
+type PathIssue struct {
+	Path   string
+	Kind   string
+	Detail string
+}
+
+// DiagnosePaths checks the paths applying to the machine described by facts for missing directories,
+// duplicates and prepended directories containing executables which shadow the ones of the system $PATH.
+func DiagnosePaths(list PathList, facts Facts) (issues []PathIssue, err error) {
+	prepended, appended, err := list.Resolve(facts)
+	if err != nil {
+		return nil, err
+	}
+	fs := factory.Default.Fs
+
+	seen := map[string]string{}
+	for i, dir := range append(append([]string{}, prepended...), appended...) {
+		expanded := expandPath(dir, facts)
+		if first, ok := seen[expanded]; ok {
+			issues = append(issues, PathIssue{Path: dir, Kind: PathDuplicate, Detail: "already added as " + first})
+			continue
+		}
+		seen[expanded] = dir
+
+		info, err := fs.Stat(expanded)
+		switch {
+		case err != nil:
+			issues = append(issues, PathIssue{Path: dir, Kind: PathMissing, Detail: expanded + " does not exist"})
+			continue
+		case !info.IsDir():
+			issues = append(issues, PathIssue{Path: dir, Kind: PathNotADirectory, Detail: expanded + " is a file"})
+			continue
+		}
+
+		if i >= len(prepended) {
+			continue
+		}
+		shadowed, err := shadowedExecutables(fs, expanded, facts)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range shadowed {
+			issues = append(issues, PathIssue{Path: dir, Kind: PathShadowing, Detail: name})
+		}
+	}
+	return issues, nil
+}
+
+// shadowedExecutables returns the executables in dir, which are also found in a directory of the system $PATH
+func shadowedExecutables(fs afero.Fs, dir string, facts Facts) (shadowed []string, err error) {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	system := filepath.SplitList(facts.Env.Get("PATH"))
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		for _, systemDir := range system {
+			if systemDir == "" || filepath.Clean(systemDir) == filepath.Clean(dir) {
+				continue
+			}
+			candidate := filepath.Join(systemDir, entry.Name())
+			if info, err := fs.Stat(candidate); err == nil && !info.IsDir() && info.Mode().Perm()&0111 != 0 {
+				shadowed = append(shadowed, entry.Name()+" shadows "+candidate)
+				break
+			}
+		}
+	}
+	sort.Strings(shadowed)
+	return shadowed, nil
+}
+
+// expandPath expands a leading ~ and the $VARIABLES in dir the way the shell would
+func expandPath(dir string, facts Facts) string {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		dir = facts.Home + dir[1:]
+	}
+	return filepath.Clean(os.Expand(dir, func(name string) string {
+		if name == "HOME" && facts.Home != "" {
+			return facts.Home
+		}
+		return facts.Env.Get(name)
+	}))
+}
@@ -0,0 +1,116 @@
+package zsh
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestPathList_YAML(t *testing.T) {
+	in := `- $GOBIN
+- value: /opt/homebrew/bin
+  when: os == "darwin"
+  position: prepend
+- value: /snap/bin
+  when: os == "linux"
+`
+	var list PathList
+	assert.NoError(t, yaml.Unmarshal([]byte(in), &list))
+	assert.Equal(t, PathList{
+		{Value: Value{Value: "$GOBIN"}, Position: PATH_APPEND},
+		{Value: Value{Value: "/opt/homebrew/bin", When: `os == "darwin"`}, Position: PATH_PREPEND},
+		{Value: Value{Value: "/snap/bin", When: `os == "linux"`}, Position: PATH_APPEND},
+	}, list)
+
+	out, err := yaml.Marshal(list)
+	assert.NoError(t, err)
+	assert.Equal(t, in, string(out))
+
+	assert.ErrorIs(t, yaml.Unmarshal([]byte("- {value: /bin, position: middle}"), &list), ErrUnknownPathPosition)
+}
+
+func TestPathList_AddRemove(t *testing.T) {
+	list := PathListOf("$GOBIN", "~/bin")
+
+	assert.NoError(t, list.Add(PathEntry{Value: Value{Value: "~/bin"}, Position: PATH_PREPEND}))
+	assert.NoError(t, list.Add(PathEntry{Value: Value{Value: "~/bin", When: `os == "linux"`}, Position: PATH_APPEND}))
+	assert.Equal(t, PathList{
+		{Value: Value{Value: "$GOBIN"}, Position: PATH_APPEND},
+		{Value: Value{Value: "~/bin"}, Position: PATH_PREPEND},
+		{Value: Value{Value: "~/bin", When: `os == "linux"`}, Position: PATH_APPEND},
+	}, list)
+
+	for _, invalid := range []string{"", " ", "/a:/b", "/a\n/b"} {
+		assert.ErrorIs(t, list.Add(PathEntry{Value: Value{Value: invalid}}), ErrInvalidPath, invalid)
+	}
+
+	assert.NoError(t, list.Remove("~/bin"))
+	assert.Equal(t, PathListOf("$GOBIN"), list)
+	assert.ErrorIs(t, list.Remove("~/bin"), ErrPathNotFound)
+}
+
+func TestRender_PrependPaths(t *testing.T) {
+	cfg := &ConfigSpec{Configs: ConfigsSpec{Paths: PathList{
+		{Value: Value{Value: "$GOBIN"}, Position: PATH_APPEND},
+		{Value: Value{Value: "/opt/homebrew/bin", When: `os == "darwin"`}, Position: PATH_PREPEND},
+		{Value: Value{Value: "/snap/bin", When: `os == "linux"`}, Position: PATH_PREPEND},
+		{Value: Value{Value: "~/bin"}, Position: PATH_PREPEND},
+	}}}
+
+	tests := map[string][]string{
+		"zsh":  {"path=(\n\t\"/opt/homebrew/bin\"\n\t~/\"bin\"\n\t$path\n)", "path+=(\n\t\"$GOBIN\"\n)"},
+		"bash": {`PATH="/opt/homebrew/bin":~/"bin":"$PATH"`, `PATH="$PATH":"$GOBIN"`},
+		"fish": {`set -gx PATH "/opt/homebrew/bin" ~/"bin" $PATH`, `set -gx PATH $PATH "$GOBIN"`},
+	}
+	for shell, expected := range tests {
+		t.Run(shell, func(t *testing.T) {
+			rendered, err := renderWithFacts(cfg, testFacts, WithShell(shell))
+			assert.NoError(t, err)
+			assertInOrder(t, rendered, expected...)
+			assert.NotContains(t, rendered, "/snap/bin")
+		})
+	}
+}
+
+func TestDiagnosePaths(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	fs := factory.Default.Fs
+	for path, perm := range map[string]int{
+		"/usr/bin/git":         0755,
+		"/usr/bin/ls":          0755,
+		"/Users/alex/bin/git":  0755,
+		"/Users/alex/bin/ls":   0644,
+		"/Users/alex/bin/tool": 0755,
+		"/Users/alex/go/bin":   0644,
+	} {
+		assert.NoError(t, afero.WriteFile(fs, path, nil, 0))
+		assert.NoError(t, fs.Chmod(path, os.FileMode(perm)))
+	}
+
+	facts := testFacts
+	facts.Env = env.Vars{"PATH": "/usr/bin:/bin", "GOBIN": "/Users/alex/go/bin"}
+	list := PathList{
+		{Value: Value{Value: "~/bin"}, Position: PATH_PREPEND},
+		{Value: Value{Value: "/usr/bin"}, Position: PATH_APPEND},
+		{Value: Value{Value: "$HOME/bin"}, Position: PATH_APPEND},
+		{Value: Value{Value: "$GOBIN"}, Position: PATH_APPEND},
+		{Value: Value{Value: "/opt/missing"}, Position: PATH_APPEND},
+		{Value: Value{Value: "/snap/bin", When: `os == "linux"`}, Position: PATH_APPEND},
+	}
+
+	issues, err := DiagnosePaths(list, facts)
+	assert.NoError(t, err)
+	assert.Equal(t, []PathIssue{
+		{Path: "~/bin", Kind: PathShadowing, Detail: "git shadows /usr/bin/git"},
+		{Path: "$HOME/bin", Kind: PathDuplicate, Detail: "already added as ~/bin"},
+		{Path: "$GOBIN", Kind: PathNotADirectory, Detail: "/Users/alex/go/bin is a file"},
+		{Path: "/opt/missing", Kind: PathMissing, Detail: "/opt/missing does not exist"},
+	}, issues)
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/ahmetb/go-linq"
-	"github.com/go-git/go-git/v5"
 	"github.com/rs/zerolog/log"
 
 	"github.com/alex-held/dfctl-kit/pkg/env"
@@ -21,35 +20,43 @@ type Plugin struct {
 	Name    string
 	Enabled bool
 	Kind    RepoKind
+	Ref     string
+	// CloneSpec holds the clone options of the plugin itself, without the global defaults
+	CloneSpec CloneSpec
+	// Subdir, File and Init select what of the checkout gets sourced
+	Subdir string
+	File   string
+	Init   string
+	// Checksum verifies the archive of url plugins
+	Checksum string
+	Load     LoadMode
+	// Commit is the exact commit to install, e.g. from the lockfile; it takes precedence over Ref
+	Commit string
 }
 
 func (p *Plugin) SetEnabled(enable bool) error {
-	cfg, err := Load()
-	if err != nil {
-		return err
-	}
-
 	p.Enabled = enable
 
-	for i := 0; i < len(cfg.Plugins.Custom); i++ {
-		custom := cfg.Plugins.Custom[i]
+	return Update(func(cfg *ConfigSpec) error {
+		for i := 0; i < len(cfg.Plugins.Custom); i++ {
+			custom := cfg.Plugins.Custom[i]
 
-		if custom.ID == p.ID {
-			if enable {
+			if custom.ID == p.ID {
+				if !enable {
+					cfg.Plugins.Custom = append(cfg.Plugins.Custom[:i], cfg.Plugins.Custom[i+1:]...)
+					return nil
+				}
+				cfg.Plugins.Custom[i].Enabled = true
 				return nil
 			}
-			cfg.Plugins.Custom = append(cfg.Plugins.Custom[:i], cfg.Plugins.Custom[i+1:]...)
-			return Save(cfg)
 		}
-	}
-
-	// plugin is not yet in config
-	if enable {
-		cfg.Plugins.Custom = append(cfg.Plugins.Custom, *p.Spec())
-		return Save(cfg)
-	}
 
-	return nil
+		// plugin is not yet in config
+		if enable {
+			cfg.Plugins.Custom = append(cfg.Plugins.Custom, *p.Spec())
+		}
+		return nil
+	})
 }
 
 func (p *Plugin) IsEnabled() bool {
@@ -58,11 +65,18 @@ func (p *Plugin) IsEnabled() bool {
 
 func PluginFromSpec(p *PluginSpec) *Plugin {
 	return &Plugin{
-		ID:      p.ID,
-		Repo:    p.Repo,
-		Name:    p.Name,
-		Kind:    p.Kind,
-		Enabled: p.Enabled,
+		ID:        p.ID,
+		Repo:      p.Repo,
+		Name:      p.Name,
+		Kind:      p.Kind,
+		Ref:       p.Ref,
+		CloneSpec: p.Clone,
+		Subdir:    p.Subdir,
+		File:      p.File,
+		Init:      p.Init,
+		Checksum:  p.Checksum,
+		Load:      p.Load,
+		Enabled:   p.Enabled,
 	}
 }
 
@@ -70,12 +84,23 @@ func strptr(s string) *string {
 	return &s
 }
 
-func NewPlugin(repoUrn string, id, name *string) (p *Plugin) {
+func NewPlugin(repoUrn string, id, name *string) (p *Plugin, err error) {
+	kind, err := PluginURN(repoUrn).GetScheme()
+	if err != nil {
+		return nil, err
+	}
 	repo := repoUrn[strings.Index(repoUrn, ":")+1:]
-	kindStr := repoUrn[:strings.Index(repoUrn, ":")]
+	if kind == PLUGIN_LOCAL {
+		if repo, err = filepath.Abs(expandPath(repo, CurrentFacts())); err != nil {
+			return nil, err
+		}
+	}
 
 	if id == nil || *id == "" {
 		id = strptr(filepath.Base(repoUrn))
+		if kind == PLUGIN_URL {
+			id = strptr(archiveName(repo))
+		}
 	}
 	if name == nil || *name == "" {
 		name = strptr(*id)
@@ -85,18 +110,25 @@ func NewPlugin(repoUrn string, id, name *string) (p *Plugin) {
 		ID:      *id,
 		Name:    *name,
 		Repo:    repo,
-		Kind:    ParsePluginKind(kindStr),
+		Kind:    kind,
 		Enabled: true,
-	}
+	}, nil
 }
 
 func (p *Plugin) Spec() *PluginSpec {
 	return &PluginSpec{
-		ID:      p.ID,
-		Repo:    p.Repo,
-		Name:    p.Name,
-		Kind:    p.Kind,
-		Enabled: p.Enabled,
+		ID:       p.ID,
+		Repo:     p.Repo,
+		Name:     p.Name,
+		Kind:     p.Kind,
+		Ref:      p.Ref,
+		Clone:    p.CloneSpec,
+		Subdir:   p.Subdir,
+		File:     p.File,
+		Init:     p.Init,
+		Checksum: p.Checksum,
+		Load:     p.Load,
+		Enabled:  p.Enabled,
 	}
 }
 
@@ -120,15 +152,12 @@ func (p *Plugin) Clone() (pluginPath string, err error) {
 		url = p.Name
 	case PLUGIN_OMZ:
 		return "", ErrOMZCloneNotSupported
+	case PLUGIN_LOCAL, PLUGIN_URL:
+		return pluginPath, p.fetch(pluginPath)
 	}
 
-	repo, err := git.PlainClone(pluginPath, false, &git.CloneOptions{URL: url})
-	if err != nil {
-		return pluginPath, err
-	}
-	_ = repo
-
-	return pluginPath, nil
+	err = cloneAt(pluginPath, cloneOptions{url: url, CloneSpec: p.CloneSpec.Or(cloneDefaults())})
+	return pluginPath, err
 }
 
 func (p *Plugin) Id() string { return p.ID }
@@ -141,23 +170,26 @@ func (p *Plugin) Install() (res InstallResult) {
 		return InstallResult{Installed: false}
 	}
 
+	if err := p.validateSource(); err != nil {
+		return InstallResult{Installed: false, Err: err}
+	}
+
 	if _, statErr := os.Stat(path); statErr == nil {
 		log.Debug().Msgf("plugin %s is already installed", p.ID)
 		return InstallResult{Installed: false}
 	}
 
-	if _, err := git.PlainClone(path, false, &git.CloneOptions{URL: BuildRepositoryURI(p.Repo, p.Kind)}); err != nil {
+	if err := p.fetch(path); err != nil {
 		return InstallResult{Installed: false, Err: err}
 	}
 
-	cfg := MustLoad()
-
-	if cfg.Plugins.ContainsWithRepo(p.Repo, p.Kind) {
-		return InstallResult{Installed: true}
-	}
-
-	cfg.Plugins.Custom = append(cfg.Plugins.Custom, *p.Spec())
-	if err := Save(cfg); err != nil {
+	err := Update(func(cfg *ConfigSpec) error {
+		if !cfg.Plugins.ContainsWithRepo(p.Repo, p.Kind) {
+			cfg.Plugins.Custom = append(cfg.Plugins.Custom, *p.Spec())
+		}
+		return nil
+	})
+	if err != nil {
 		log.Error().Err(err).Msgf("unable to save plugin %s to config file", p.ID)
 		return InstallResult{Installed: true, Err: err}
 	}
@@ -165,6 +197,22 @@ func (p *Plugin) Install() (res InstallResult) {
 	return InstallResult{Installed: true}
 }
 
+// fetch links, downloads or clones the plugin to path, depending on its kind
+func (p *Plugin) fetch(path string) error {
+	switch p.Kind {
+	case PLUGIN_LOCAL:
+		return linkLocal(path, p.Repo)
+	case PLUGIN_URL:
+		return downloadArchive(path, p.Repo, p.Checksum)
+	}
+	return cloneAt(path, cloneOptions{
+		url:       BuildRepositoryURI(p.Repo, p.Kind),
+		ref:       p.Ref,
+		commit:    p.Commit,
+		CloneSpec: p.CloneSpec.Or(cloneDefaults()),
+	})
+}
+
 func BuildRepositoryURI(repo string, kind RepoKind) string {
 	if kind == PLUGIN_GITHUB {
 		return "https://github.com/" + repo
@@ -0,0 +1,207 @@
+package zsh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+// WithProfile instruments the rendered zshrc to log the load times of its sections and sourced files to logFile
+func WithProfile(logFile string) RenderOption {
+	return func(o *RenderOptions) {
+		o.Profile = logFile
+	}
+}
+
+const (
+	ProfileSection = "section"
+	ProfilePlugin  = "plugin"
+	ProfileTheme   = "theme"
+	ProfileSource  = "source"
+)
+
+// ProfileEntry is the load time of a section of the zshrc or of a file sourced while loading it
+type ProfileEntry struct {
+	Kind     string
+	Name     string
+	Duration time.Duration
+	// Depth is the number of files sourcing the entry, e.g. 1 for plugins sourced by oh-my-zsh.sh
+	Depth int
+}
+
+// Profile contains the load times of the sections, followed by those of the sourced files in the order they got sourced
+type Profile struct {
+	Total   time.Duration
+	Entries []ProfileEntry
+}
+
+// RunProfile starts an interactive zsh loading the instrumented zshrc and returns the measured load times.
+//
+// The instrumentation wraps `source` into a function, so declarations at the top level of sourced files
+// become local to it; this is fine for the throwaway profiling shell, but its state is not meant to be used.
+func RunProfile(opts ...RenderOption) (profile *Profile, err error) {
+	zsh, err := lookPath("zsh")
+	if err != nil {
+		return nil, fmt.Errorf("profiling requires zsh: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "dfctl-profile-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "profile.log")
+	rendered, err := Source(append(opts, WithProfile(logFile))...)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, ".zshrc"), []byte(rendered), configFilePerm); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(zsh, "-i", "-c", "exit")
+	cmd.Env = append(os.Environ(), "ZDOTDIR="+dir)
+	cmd.Stdout, cmd.Stderr = io.Discard, io.Discard
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("profiling zsh exited with %w", err)
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProfile(f)
+}
+
+func profileSection(logFile string) func(name string) string {
+	return func(name string) string {
+		if logFile == "" {
+			return ""
+		}
+		return "_dfctl_profile_section " + name + "\n"
+	}
+}
+
+// instrument wraps the rendered zshrc into the functions logging the load times to logFile
+func instrument(rendered, logFile string) string {
+	log := qpath(logFile)
+	return `zmodload zsh/datetime
+typeset -gi _dfctl_profile_depth=0
+_dfctl_profile_section() {
+	print -r -- "section	$1	$EPOCHREALTIME" >>| ` + log + `
+}
+_dfctl_profile_source() {
+	local start=$EPOCHREALTIME depth=$_dfctl_profile_depth rc
+	(( _dfctl_profile_depth++ ))
+	builtin source "$@"
+	rc=$?
+	(( _dfctl_profile_depth-- ))
+	print -r -- "source	$1	$start	$EPOCHREALTIME	$depth" >>| ` + log + `
+	return $rc
+}
+source() { _dfctl_profile_source "$@" }
+_dfctl_profile_section begin
+` + rendered + `
+_dfctl_profile_section end
+`
+}
+
+func parseProfile(r io.Reader) (profile *Profile, err error) {
+	profile = &Profile{}
+
+	type mark struct {
+		name string
+		at   float64
+	}
+	type source struct {
+		ProfileEntry
+		start float64
+	}
+	var sections []mark
+	var sources []source
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		switch {
+		case fields[0] == ProfileSection && len(fields) == 3:
+			at, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile line %d: %w", line, err)
+			}
+			sections = append(sections, mark{fields[1], at})
+		case fields[0] == ProfileSource && len(fields) == 5:
+			start, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile line %d: %w", line, err)
+			}
+			end, err := strconv.ParseFloat(fields[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile line %d: %w", line, err)
+			}
+			depth, err := strconv.Atoi(fields[4])
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile line %d: %w", line, err)
+			}
+			kind, name := classifySource(fields[1])
+			sources = append(sources, source{ProfileEntry{Kind: kind, Name: name, Duration: seconds(end - start), Depth: depth}, start})
+		default:
+			return nil, fmt.Errorf("invalid profile line %d: %q", line, scanner.Text())
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(sections); i++ {
+		if sections[i].name == "begin" {
+			continue
+		}
+		profile.Entries = append(profile.Entries, ProfileEntry{Kind: ProfileSection, Name: sections[i].name, Duration: seconds(sections[i+1].at - sections[i].at)})
+	}
+	if len(sections) > 1 {
+		profile.Total = seconds(sections[len(sections)-1].at - sections[0].at)
+	}
+
+	// sources are logged when they finished loading, i.e. nested ones first
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].start < sources[j].start })
+	for _, s := range sources {
+		profile.Entries = append(profile.Entries, s.ProfileEntry)
+	}
+	return profile, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// classifySource returns whether the sourced file at path belongs to a plugin, a theme or is any other file
+func classifySource(path string) (kind, name string) {
+	within := func(dirs ...string) (string, bool) {
+		for _, dir := range dirs {
+			if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0], true
+			}
+		}
+		return "", false
+	}
+
+	if name, ok := within(env.Plugins(), filepath.Join(env.OMZ(), "plugins")); ok {
+		return ProfilePlugin, name
+	}
+	if name, ok := within(env.Themes(), filepath.Join(env.OMZ(), "themes")); ok {
+		return ProfileTheme, strings.TrimSuffix(name, ".zsh-theme")
+	}
+	return ProfileSource, path
+}
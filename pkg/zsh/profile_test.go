@@ -0,0 +1,89 @@
+package zsh
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func TestParseProfile(t *testing.T) {
+	log := strings.Join([]string{
+		"section\tbegin\t100.000000",
+		"section\tglobals\t100.000000",
+		"section\tplugins\t100.010000",
+		"source\t" + filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.zsh") + "\t100.030000\t100.080000\t1",
+		"source\t" + filepath.Join(env.OMZ(), "oh-my-zsh.sh") + "\t100.020000\t100.150000\t0",
+		"section\tpost-source\t100.150000",
+		"source\t" + filepath.Join(env.Themes(), "agnoster.zsh-theme") + "\t100.160000\t100.170000\t0",
+		"source\t/Users/alex/.p10k.zsh\t100.170000\t100.200000\t0",
+		"section\tend\t100.200000",
+	}, "\n")
+
+	profile, err := parseProfile(strings.NewReader(log))
+	assert.NoError(t, err)
+	assert.InDelta(t, 200*time.Millisecond, profile.Total, float64(time.Microsecond))
+
+	var got []string
+	for _, entry := range profile.Entries {
+		got = append(got, entry.Kind+" "+strings.Repeat(">", entry.Depth)+entry.Name+" "+entry.Duration.Round(time.Millisecond).String())
+	}
+	assert.Equal(t, []string{
+		"section globals 10ms",
+		"section plugins 140ms",
+		"section post-source 50ms",
+		"source " + filepath.Join(env.OMZ(), "oh-my-zsh.sh") + " 130ms",
+		"plugin >zsh-autosuggestions 50ms",
+		"theme agnoster 10ms",
+		"source /Users/alex/.p10k.zsh 30ms",
+	}, got)
+
+	_, err = parseProfile(strings.NewReader("section\tglobals\tnot-a-time"))
+	assert.Error(t, err)
+}
+
+func TestRender_Profile(t *testing.T) {
+	cfg := &ConfigSpec{Exports: ValuesOf(map[string]string{"A": "b"})}
+
+	plain, err := renderWithFacts(cfg, testFacts)
+	assert.NoError(t, err)
+	assert.NotContains(t, plain, "_dfctl_profile")
+
+	profiled, err := renderWithFacts(cfg, testFacts, WithProfile("/tmp/dfctl profile.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, profiled, `>>| "/tmp/dfctl profile.log"`)
+	assertInOrder(t, profiled,
+		"source() { _dfctl_profile_source \"$@\" }",
+		"_dfctl_profile_section begin",
+		"_dfctl_profile_section globals",
+		"_dfctl_profile_section exports",
+		"export A=\"b\"",
+		"_dfctl_profile_section post-source",
+		"_dfctl_profile_section end",
+	)
+}
+
+func TestRunProfile(t *testing.T) {
+	if _, err := exec.LookPath("zsh"); err != nil {
+		t.Skip("zsh is not installed")
+	}
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	assert.NoError(t, Save(&ConfigSpec{Framework: FRAMEWORK_NONE, Exports: ValuesOf(map[string]string{"A": "b"})}))
+
+	profile, err := RunProfile()
+	assert.NoError(t, err)
+	assert.Greater(t, int64(profile.Total), int64(0))
+
+	var sections []string
+	for _, entry := range profile.Entries {
+		if entry.Kind == ProfileSection {
+			sections = append(sections, entry.Name)
+		}
+	}
+	assert.Equal(t, []string{"globals", "exports", "path", "pre-source", "omz-config", "plugins", "framework", "user-config", "aliases", "options", "post-source"}, sections)
+}
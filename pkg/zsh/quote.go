@@ -0,0 +1,207 @@
+package zsh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	// safeWord matches words which need no quoting at all
+	safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+	// parameterReference matches a `$NAME` or `${NAME}` parameter expansion at the start of a string
+	parameterReference = regexp.MustCompile(`^\$(\{[A-Za-z_][A-Za-z0-9_]*}|[A-Za-z_][A-Za-z0-9_]*)`)
+
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	aliasName    = regexp.MustCompile(`^[A-Za-z0-9_.:@%+,!^][A-Za-z0-9_.:@%+,!^-]*$`)
+	optionName   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+var ErrInvalidName = fmt.Errorf("invalid name")
+
+// quote quotes s as a single literal zsh word; nothing inside of it gets expanded.
+// Words consisting of safe characters only are returned as is.
+func quote(s string) string {
+	if safeWord.MatchString(s) {
+		return s
+	}
+	if strings.IndexFunc(s, isControl) < 0 {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	return ansiQuote(s)
+}
+
+// dquote quotes s as a double-quoted zsh word which only expands `$NAME` and `${NAME}` parameter references.
+// Command substitutions, backticks and other expansions are escaped; control characters like newlines are
+// emitted as $'..' strings concatenated to the double-quoted parts.
+func dquote(s string) string {
+	sb := &strings.Builder{}
+	open := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isControl(rune(c)) {
+			if open {
+				sb.WriteByte('"')
+				open = false
+			}
+			sb.WriteString(ansiQuote(string(c)))
+			continue
+		}
+		if !open {
+			sb.WriteByte('"')
+			open = true
+		}
+		switch c {
+		case '"', '\\', '`':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '$':
+			if ref := parameterReference.FindString(s[i:]); ref != "" {
+				sb.WriteString(ref)
+				i += len(ref) - 1
+				continue
+			}
+			sb.WriteString(`\$`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if sb.Len() == 0 {
+		return `""`
+	}
+	if open {
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+// qpath quotes the path p like dquote but keeps a leading `~` unquoted, so that it still expands to the home directory
+func qpath(p string) string {
+	return qpathWith(p, dquote)
+}
+
+func qpathWith(p string, dquote func(string) string) string {
+	switch {
+	case p == "~":
+		return p
+	case strings.HasPrefix(p, "~/"):
+		return "~/" + dquote(strings.TrimPrefix(p, "~/"))
+	default:
+		return dquote(p)
+	}
+}
+
+func ansiQuote(s string) string {
+	sb := &strings.Builder{}
+	sb.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '\'':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if isControl(rune(c)) {
+				fmt.Fprintf(sb, `\x%02x`, c)
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// validateNames ensures that all names the renderer writes unquoted into the zshrc are valid zsh identifiers
+func validateNames(cfg *ConfigSpec) error {
+	for _, export := range cfg.Exports {
+		if err := validateName("export", variableName, export.Name); err != nil {
+			return err
+		}
+	}
+	for _, config := range cfg.Configs.User {
+		if err := validateName("user config", variableName, config.Name); err != nil {
+			return err
+		}
+	}
+	for _, config := range cfg.Configs.OMZ {
+		if err := validateName("omz config", variableName, config.Key); err != nil {
+			return err
+		}
+	}
+	for _, alias := range cfg.Aliases {
+		if err := validateName("alias", aliasName, alias.Key); err != nil {
+			return err
+		}
+	}
+	for _, option := range cfg.Configs.ZshOptions {
+		if err := validateName("zsh option", optionName, option.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateName(kind string, pattern *regexp.Regexp, name string) error {
+	if !pattern.MatchString(name) {
+		return fmt.Errorf("%w: %s %q", ErrInvalidName, kind, name)
+	}
+	return nil
+}
+
+// fishFuncs replace the quoting functions of the templates for fish, whose quoting rules differ from zsh
+var fishFuncs = template.FuncMap{
+	"quote":  fishQuote,
+	"dquote": fishDquote,
+	"qpath": func(p string) string {
+		return qpathWith(p, fishDquote)
+	},
+}
+
+// fishQuote quotes s as a single literal fish word
+func fishQuote(s string) string {
+	if safeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// fishDquote quotes s as a double-quoted fish word which only expands `$NAME` and `${NAME}` variable references
+func fishDquote(s string) string {
+	sb := &strings.Builder{}
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '$':
+			ref := parameterReference.FindString(s[i:])
+			if ref == "" {
+				sb.WriteString(`\$`)
+				continue
+			}
+			i += len(ref) - 1
+			if strings.HasPrefix(ref, "${") {
+				// fish delimits variable names by {$NAME} instead of ${NAME}
+				ref = "{$" + strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}") + "}"
+			}
+			sb.WriteString(ref)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package zsh
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuote(t *testing.T) {
+	tt := []struct {
+		in     string
+		quote  string
+		dquote string
+	}{
+		{in: "", quote: "''", dquote: `""`},
+		{in: "kubectl", quote: "kubectl", dquote: `"kubectl"`},
+		{in: "$HOME/go", quote: `'$HOME/go'`, dquote: `"$HOME/go"`},
+		{in: "${GOPATH}/bin", quote: `'${GOPATH}/bin'`, dquote: `"${GOPATH}/bin"`},
+		{in: `say "hi"`, quote: `'say "hi"'`, dquote: `"say \"hi\""`},
+		{in: "it's", quote: `'it'\''s'`, dquote: `"it's"`},
+		{in: "$(rm -rf ~) `id` $1", quote: "'$(rm -rf ~) `id` $1'", dquote: "\"\\$(rm -rf ~) \\`id\\` \\$1\""},
+		{in: `C:\dir`, quote: `'C:\dir'`, dquote: `"C:\\dir"`},
+		{in: "a\nb", quote: `$'a\nb'`, dquote: `"a"$'\n'"b"`},
+		{in: "\t", quote: `$'\t'`, dquote: `$'\t'`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.quote, quote(tc.in))
+			assert.Equal(t, tc.dquote, dquote(tc.in))
+		})
+	}
+}
+
+func TestQuote_Zsh(t *testing.T) {
+	zsh, err := exec.LookPath("zsh")
+	if err != nil {
+		t.Skip("zsh is not installed")
+	}
+
+	for _, in := range []string{"", "plain", `"; echo injected; "`, "$(echo injected)", "`echo injected`", "a'b\\c\nd\te", "$HOME"} {
+		out, err := exec.Command(zsh, "-f", "-c", "HOME=/home/dfctl; print -rn -- "+quote(in)+" "+dquote(in)).Output()
+		assert.NoError(t, err)
+
+		expanded := in
+		if in == "$HOME" {
+			expanded = "/home/dfctl"
+		}
+		assert.Equal(t, in+" "+expanded, string(out))
+	}
+}
+
+func TestQPath(t *testing.T) {
+	assert.Equal(t, "~", qpath("~"))
+	assert.Equal(t, `~/".p10k.zsh"`, qpath("~/.p10k.zsh"))
+	assert.Equal(t, `"$HOME/my scripts/init.zsh"`, qpath("$HOME/my scripts/init.zsh"))
+}
+
+func TestRender_InvalidNames(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  *ConfigSpec
+	}{
+		{name: "export", cfg: &ConfigSpec{Exports: ValuesOf(map[string]string{"FOO=$(id)": "bar"})}},
+		{name: "user config", cfg: &ConfigSpec{Configs: ConfigsSpec{User: ValuesOf(map[string]string{"1ST": "bar"})}}},
+		{name: "alias", cfg: &ConfigSpec{Aliases: KeyValuesOf(map[string]string{"ls; id": "ls"})}},
+		{name: "zsh option", cfg: &ConfigSpec{Configs: ConfigsSpec{ZshOptions: OptionsOf(map[string]bool{"auto cd": true})}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := renderWithFacts(tc.cfg, testFacts)
+			assert.ErrorIs(t, err, ErrInvalidName)
+		})
+	}
+}
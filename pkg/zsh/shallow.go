@@ -0,0 +1,137 @@
+package zsh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// openRepository opens the clone at path. The commits at the boundary of a shallow clone appear as root commits,
+// like they do for git itself; go-git fails walking their missing parents otherwise, e.g. when fetching updates.
+func openRepository(path string) (repo *git.Repository, err error) {
+	repo, err = git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Storer.Shallow()
+	if err != nil || len(commits) == 0 {
+		return repo, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	s := &shallowStorage{Storer: repo.Storer}
+	s.setBoundary(commits)
+	return git.Open(s, worktree.Filesystem)
+}
+
+// pruneShallow removes the commits whose parents got fetched by deepening the history from the boundary of a shallow clone.
+// go-git only ever adds to the boundary, so that those commits would keep appearing as root commits.
+func pruneShallow(repo *git.Repository) error {
+	var objects storer.EncodedObjectStorer = repo.Storer
+	if s, ok := repo.Storer.(*shallowStorage); ok {
+		objects = s.Storer
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil || len(shallow) == 0 {
+		return err
+	}
+	var boundary []plumbing.Hash
+	for _, hash := range shallow {
+		commit, err := object.GetCommit(objects, hash)
+		if err != nil {
+			return err
+		}
+		for _, parent := range commit.ParentHashes {
+			if _, err = objects.EncodedObject(plumbing.CommitObject, parent); err != nil {
+				boundary = append(boundary, hash)
+				break
+			}
+		}
+	}
+	if len(boundary) == len(shallow) {
+		return nil
+	}
+	return repo.Storer.SetShallow(boundary)
+}
+
+// shallowStorage grafts the boundary commits of a shallow clone as root commits
+type shallowStorage struct {
+	storage.Storer
+	boundary map[plumbing.Hash]bool
+}
+
+func (s *shallowStorage) setBoundary(commits []plumbing.Hash) {
+	s.boundary = map[plumbing.Hash]bool{}
+	for _, commit := range commits {
+		s.boundary[commit] = true
+	}
+}
+
+func (s *shallowStorage) SetShallow(commits []plumbing.Hash) error {
+	if err := s.Storer.SetShallow(commits); err != nil {
+		return err
+	}
+	s.setBoundary(commits)
+	return nil
+}
+
+func (s *shallowStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.Storer.EncodedObject(t, h)
+	if err != nil || !s.boundary[h] || obj.Type() != plumbing.CommitObject {
+		return obj, err
+	}
+	return graft(obj)
+}
+
+// PackfileWriter lets fetches write packfiles directly, instead of storing each object on its own
+func (s *shallowStorage) PackfileWriter() (io.WriteCloser, error) {
+	if pw, ok := s.Storer.(storer.PackfileWriter); ok {
+		return pw.PackfileWriter()
+	}
+	return nil, plumbing.ErrObjectNotFound
+}
+
+// graftedObject is a commit without its parents, which keeps the hash of the original commit
+type graftedObject struct {
+	*plumbing.MemoryObject
+	hash plumbing.Hash
+}
+
+func (o graftedObject) Hash() plumbing.Hash {
+	return o.hash
+}
+
+// graft removes the parent headers of commit
+func graft(commit plumbing.EncodedObject) (plumbing.EncodedObject, error) {
+	r, err := commit.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	grafted := &plumbing.MemoryObject{}
+	grafted.SetType(plumbing.CommitObject)
+	header, message := content, []byte{}
+	if i := bytes.Index(content, []byte("\n\n")); i >= 0 {
+		header, message = content[:i], content[i:]
+	}
+	for _, line := range bytes.SplitAfter(header, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("parent ")) {
+			_, _ = grafted.Write(line)
+		}
+	}
+	_, _ = grafted.Write(message)
+	return graftedObject{MemoryObject: grafted, hash: commit.Hash()}, nil
+}
@@ -0,0 +1,198 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Renderer renders the startup file of a shell from a config
+type Renderer interface {
+	Render(cfg *ConfigSpec, facts Facts, opts *RenderOptions) (rendered string, err error)
+}
+
+// Shells are the shells startup files can be rendered for
+var Shells = []string{"zsh", "bash", "fish"}
+
+var ErrUnsupportedShell = fmt.Errorf("unsupported shell")
+
+// RendererFor returns the Renderer of shell; an empty shell defaults to zsh
+func RendererFor(shell string) (Renderer, error) {
+	switch strings.ToLower(shell) {
+	case "", "zsh":
+		return zshRenderer{}, nil
+	case "bash":
+		return &portableRenderer{shell: "bash", tmpl: bashTmpl, sources: isBashScript}, nil
+	case "fish":
+		return &portableRenderer{shell: "fish", tmpl: fishTmpl, sources: isFishScript, funcs: fishFuncs}, nil
+	default:
+		return nil, fmt.Errorf("%w %q; expected one of %v", ErrUnsupportedShell, shell, Shells)
+	}
+}
+
+// portableRenderer renders the shell-neutral parts of a config, i.e. exports, paths, sources, user configs and aliases.
+// zsh only features are skipped with a warning.
+type portableRenderer struct {
+	shell   string
+	tmpl    string
+	sources func(script string) bool
+	funcs   template.FuncMap
+}
+
+func (r *portableRenderer) Render(cfg *ConfigSpec, facts Facts, _ *RenderOptions) (rendered string, err error) {
+	r.warnZshOnly(cfg)
+
+	data, err := newRenderData(cfg, facts)
+	if err != nil {
+		return rendered, err
+	}
+	data.PreSources = r.filterSources(data.PreSources)
+	data.PostSources = r.filterSources(data.PostSources)
+
+	tpl, err := template.New(r.shell).Funcs(templateFuncs(facts)).Funcs(r.funcs).Parse(r.tmpl)
+	if err != nil {
+		return rendered, err
+	}
+	return execute(tpl, data)
+}
+
+func (r *portableRenderer) warnZshOnly(cfg *ConfigSpec) {
+	warn := func(feature string, configured bool) {
+		if configured {
+			log.Warn().Msgf("skipping %s; not supported by %s", feature, r.shell)
+		}
+	}
+	warn("framework", cfg.Framework != "")
+	warn("theme", cfg.Theme != "")
+	warn("omz plugins", len(cfg.Plugins.OMZ) > 0)
+	warn("plugins", len(cfg.Plugins.Custom) > 0)
+	warn("omz configs", len(cfg.Configs.OMZ) > 0)
+	warn("zsh options", len(cfg.Configs.ZshOptions) > 0)
+}
+
+func (r *portableRenderer) filterSources(scripts []string) (filtered []string) {
+	for _, script := range scripts {
+		if !r.sources(script) {
+			log.Warn().Msgf("skipping source %s; it is no %s script", script, r.shell)
+			continue
+		}
+		filtered = append(filtered, script)
+	}
+	return filtered
+}
+
+func isBashScript(script string) bool {
+	return filepath.Ext(script) != ".zsh" && filepath.Ext(script) != ".fish"
+}
+
+func isFishScript(script string) bool {
+	return filepath.Ext(script) == ".fish"
+}
+
+var bashTmpl = `
+###############################################################################
+# EXPORTS
+##
+{{- range $export := .Exports }}
+export {{ $export.Key }}={{ dquote $export.Value }}
+{{- end }}
+
+
+###############################################################################
+# PATH
+##
+{{- if .PrependPaths }}
+PATH={{ range $path := .PrependPaths }}{{ qpath $path }}:{{ end }}"$PATH"
+{{- end }}
+{{- range $path := .Paths }}
+PATH="$PATH":{{ qpath $path }}
+{{- end }}
+export PATH
+
+
+###############################################################################
+# PRE SOURCE
+##
+{{- range $script := .PreSources }}
+[[ ! -f {{ qpath $script }} ]] || source {{ qpath $script }}
+{{- end }}
+
+
+###############################################################################
+# USER CONFIG
+##
+{{- range $config := .UserConfigs }}
+export {{ $config.Key }}={{ dquote $config.Value }}
+{{- end }}
+
+
+###############################################################################
+# ALIASES
+##
+{{- range $alias := .Aliases }}
+alias {{ $alias.Key }}={{ quote $alias.Value }}
+{{- end }}
+
+
+###############################################################################
+# POST SOURCE
+##
+{{- range $script := .PostSources }}
+[[ ! -f {{ qpath $script }} ]] || source {{ qpath $script }}
+{{- end }}
+`
+
+var fishTmpl = `
+###############################################################################
+# EXPORTS
+##
+{{- range $export := .Exports }}
+set -gx {{ $export.Key }} {{ dquote $export.Value }}
+{{- end }}
+
+
+###############################################################################
+# PATH
+##
+{{- if .PrependPaths }}
+set -gx PATH{{ range $path := .PrependPaths }} {{ qpath $path }}{{ end }} $PATH
+{{- end }}
+{{- range $path := .Paths }}
+set -gx PATH $PATH {{ qpath $path }}
+{{- end }}
+
+
+###############################################################################
+# PRE SOURCE
+##
+{{- range $script := .PreSources }}
+test -f {{ qpath $script }}; and source {{ qpath $script }}
+{{- end }}
+
+
+###############################################################################
+# USER CONFIG
+##
+{{- range $config := .UserConfigs }}
+set -gx {{ $config.Key }} {{ dquote $config.Value }}
+{{- end }}
+
+
+###############################################################################
+# ALIASES
+##
+{{- range $alias := .Aliases }}
+alias {{ $alias.Key }} {{ quote $alias.Value }}
+{{- end }}
+
+
+###############################################################################
+# POST SOURCE
+##
+{{- range $script := .PostSources }}
+test -f {{ qpath $script }}; and source {{ qpath $script }}
+{{- end }}
+`
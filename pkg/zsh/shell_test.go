@@ -0,0 +1,97 @@
+package zsh
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func shellTestConfig() *ConfigSpec {
+	return &ConfigSpec{
+		Theme: "simple",
+		Plugins: PluginsSpec{
+			OMZ: OMZPluginList("git"),
+		},
+		Exports: Values{
+			{Name: "GOPATH", Value: Value{Value: "${{ home }}/go"}},
+			{Name: "GOBIN", Value: Value{Value: "${GOPATH}/bin"}},
+		},
+		Configs: ConfigsSpec{
+			Paths:      PathListOf("$GOBIN", "~/bin"),
+			ZshOptions: Options{{Name: "autocd", Enabled: true}},
+		},
+		Source: SourceSpec{
+			Post: ValueListOf("~/.p10k.zsh", "~/.bash_local", "~/.config/fish/local.fish"),
+		},
+		Aliases: KeyValues{{Key: "k", Value: "kubectl"}, {Key: "gs", Value: "git status $(pwd)"}},
+	}
+}
+
+func TestRender_Bash(t *testing.T) {
+	rendered, err := renderWithFacts(shellTestConfig(), testFacts, WithShell("bash"))
+	assert.NoError(t, err)
+
+	assertInOrder(t, rendered,
+		`export GOPATH="/Users/alex/go"`,
+		`export GOBIN="${GOPATH}/bin"`,
+		`PATH="$PATH":"$GOBIN"`,
+		`PATH="$PATH":~/"bin"`,
+		"export PATH",
+		"alias k=kubectl",
+		`alias gs='git status $(pwd)'`,
+		`source ~/".bash_local"`,
+	)
+	for _, skipped := range []string{"setopt", "oh-my-zsh", "ZSH_THEME", "plugins", ".p10k.zsh", "local.fish"} {
+		assert.NotContains(t, rendered, skipped)
+	}
+
+	if bash, err := exec.LookPath("bash"); err == nil {
+		out, err := exec.Command(bash, "-n", "-c", rendered).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+}
+
+func TestRender_Fish(t *testing.T) {
+	rendered, err := renderWithFacts(shellTestConfig(), testFacts, WithShell("fish"))
+	assert.NoError(t, err)
+
+	assertInOrder(t, rendered,
+		`set -gx GOPATH "/Users/alex/go"`,
+		`set -gx GOBIN "{$GOPATH}/bin"`,
+		`set -gx PATH $PATH "$GOBIN"`,
+		`set -gx PATH $PATH ~/"bin"`,
+		"alias k kubectl",
+		`alias gs 'git status $(pwd)'`,
+		`test -f ~/".config/fish/local.fish"; and source ~/".config/fish/local.fish"`,
+	)
+	assert.NotContains(t, rendered, "setopt")
+	assert.NotContains(t, rendered, ".p10k.zsh")
+	assert.NotContains(t, rendered, ".bash_local")
+}
+
+func TestRendererFor_Unsupported(t *testing.T) {
+	_, err := RendererFor("tcsh")
+	assert.ErrorIs(t, err, ErrUnsupportedShell)
+}
+
+func TestFishQuote(t *testing.T) {
+	tt := []struct {
+		in     string
+		quote  string
+		dquote string
+	}{
+		{in: "kubectl", quote: "kubectl", dquote: `"kubectl"`},
+		{in: "$HOME/go", quote: `'$HOME/go'`, dquote: `"$HOME/go"`},
+		{in: "${HOME}go", quote: `'${HOME}go'`, dquote: `"{$HOME}go"`},
+		{in: `it's "\"`, quote: `'it\'s "\\"'`, dquote: `"it's \"\\\""`},
+		{in: "$(id) `id`", quote: "'$(id) `id`'", dquote: "\"\\$(id) `id`\""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.quote, fishQuote(tc.in))
+			assert.Equal(t, tc.dquote, fishDquote(tc.in))
+		})
+	}
+}
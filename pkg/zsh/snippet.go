@@ -0,0 +1,68 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+var ErrInvalidPluginSource = fmt.Errorf("invalid plugin source")
+
+// Dir returns the directory the plugin lives in, the Subdir of its checkout if it is set
+func (p *Plugin) Dir() string {
+	return filepath.Join(p.Path(), filepath.FromSlash(p.Subdir))
+}
+
+// IsSnippet reports whether the plugin is a single File of its checkout rather than a directory
+func (p *Plugin) IsSnippet() bool {
+	return p.File != ""
+}
+
+// selectsSource reports whether Subdir, File or Init select what of the checkout gets sourced
+func (p *Plugin) selectsSource() bool {
+	return p.Subdir != "" || p.File != "" || p.Init != ""
+}
+
+// validateSource checks that Subdir, File and Init stay within the checkout and that File is not combined with the others
+func (p *Plugin) validateSource() error {
+	if p.File != "" && (p.Subdir != "" || p.Init != "") {
+		return fmt.Errorf("%w: plugin %s sets file, which excludes subdir and init", ErrInvalidPluginSource, p.ID)
+	}
+	for _, selector := range []struct{ rel, base string }{{p.Subdir, p.Path()}, {p.File, p.Path()}, {p.Init, p.Dir()}} {
+		if filepath.IsAbs(selector.rel) || !within(p.Path(), filepath.Join(selector.base, filepath.FromSlash(selector.rel))) {
+			return fmt.Errorf("%w: %s of plugin %s is outside of its checkout", ErrInvalidPluginSource, selector.rel, p.ID)
+		}
+	}
+	return nil
+}
+
+// selectedInit returns the file selected by File or Init; it is empty when the init file gets discovered in Dir
+func (p *Plugin) selectedInit() string {
+	switch {
+	case p.File != "":
+		return filepath.Join(p.Path(), filepath.FromSlash(p.File))
+	case p.Init != "":
+		return filepath.Join(p.Dir(), filepath.FromSlash(p.Init))
+	}
+	return ""
+}
+
+// initFile returns the selected file of the plugin, if it exists, or else the init file found in its Dir
+func (p *Plugin) initFile() (init string, ok bool) {
+	if init = p.selectedInit(); init == "" {
+		return findInitFile(p.Dir())
+	}
+	if info, err := factory.Default.Fs.Stat(init); err != nil || info.IsDir() {
+		return "", false
+	}
+	return init, true
+}
+
+// describeSource names what of the plugin gets sourced, for warnings
+func (p *Plugin) describeSource() string {
+	if init := p.selectedInit(); init != "" {
+		return init
+	}
+	return p.Dir()
+}
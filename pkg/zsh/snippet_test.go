@@ -0,0 +1,135 @@
+package zsh
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+func TestPlugin_ValidateSource(t *testing.T) {
+	for name, tc := range map[string]struct {
+		spec  PluginSpec
+		valid bool
+	}{
+		"no selectors":         {spec: PluginSpec{}, valid: true},
+		"subdir and init":      {spec: PluginSpec{Subdir: "modules/git", Init: "../../lib/init.zsh"}, valid: true},
+		"file":                 {spec: PluginSpec{File: "lib/git.zsh"}, valid: true},
+		"file and init":        {spec: PluginSpec{File: "lib/git.zsh", Init: "init.zsh"}},
+		"file and subdir":      {spec: PluginSpec{File: "lib/git.zsh", Subdir: "lib"}},
+		"absolute file":        {spec: PluginSpec{File: "/etc/zshrc"}},
+		"subdir outside":       {spec: PluginSpec{Subdir: "../other"}},
+		"init outside subdirs": {spec: PluginSpec{Subdir: "modules/git", Init: "../../../init.zsh"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.spec.ID, tc.spec.Name, tc.spec.Kind = "prezto", "prezto", PLUGIN_GITHUB
+			err := PluginFromSpec(&tc.spec).validateSource()
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidPluginSource)
+		})
+	}
+}
+
+func TestRender_PluginSelectors(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	prezto := filepath.Join(env.Plugins(), "prezto")
+	for _, file := range []string{
+		filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh"),
+		filepath.Join(prezto, "modules", "history", "init.zsh"),
+		filepath.Join(prezto, "modules", "utility", "functions", "mkcd"),
+		filepath.Join(prezto, "modules", "utility", "utility.zsh"),
+		filepath.Join(env.Plugins(), "snippets", "lib", "clipboard.zsh"),
+		filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh"),
+	} {
+		require.NoError(t, afero.WriteFile(factory.Default.Fs, file, nil, 0644))
+	}
+	cfg := &ConfigSpec{Plugins: PluginsSpec{
+		OMZ: OMZPluginList("git"),
+		Custom: PluginsList{
+			{ID: "history", Name: "prezto", Repo: "sorin-ionescu/prezto", Kind: PLUGIN_GITHUB, Enabled: true, Subdir: "modules/history"},
+			{ID: "utility", Name: "prezto", Repo: "sorin-ionescu/prezto", Kind: PLUGIN_GITHUB, Enabled: true, Subdir: "modules/utility", Init: "utility.zsh"},
+			{ID: "clipboard", Name: "snippets", Repo: "me/snippets", Kind: PLUGIN_GITHUB, Enabled: true, File: "lib/clipboard.zsh"},
+			{ID: "zsh-autosuggestions", Name: "zsh-autosuggestions", Repo: "zsh-users/zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true},
+			{ID: "missing", Name: "snippets", Repo: "me/snippets", Kind: PLUGIN_GITHUB, Enabled: true, File: "lib/missing.zsh"},
+		},
+	}}
+	history := filepath.Join(prezto, "modules", "history")
+	utility := filepath.Join(prezto, "modules", "utility", "utility.zsh")
+	clipboard := filepath.Join(env.Plugins(), "snippets", "lib", "clipboard.zsh")
+	autosuggestions := filepath.Join(env.Plugins(), "zsh-autosuggestions")
+
+	t.Run("omz", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_OMZ
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered,
+			"fpath+=(", fmt.Sprintf("%q", history), fmt.Sprintf("%q", filepath.Dir(utility)), fmt.Sprintf("%q", autosuggestions), ")",
+			"plugins=(\n\t\tgit\n)",
+			"source $ZSH/oh-my-zsh.sh",
+			fmt.Sprintf("source %q", filepath.Join(history, "init.zsh")),
+			fmt.Sprintf("source %q", utility),
+			fmt.Sprintf("source %q", clipboard),
+			fmt.Sprintf("source %q", filepath.Join(autosuggestions, "zsh-autosuggestions.plugin.zsh")),
+		)
+		assert.NotContains(t, rendered, fmt.Sprintf("%q\n", filepath.Join(env.Plugins(), "snippets")), "snippets are not added to fpath")
+		assert.NotContains(t, rendered, "missing.zsh", "missing files are skipped")
+	})
+
+	t.Run("omz before", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_OMZ
+		cfg.Plugins.Custom[2].Before = []string{"git"}
+		defer func() { cfg.Plugins.Custom[2].Before = nil }()
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered,
+			"fpath+=(", fmt.Sprintf("%q", filepath.Join(env.OMZ(), "plugins", "git")), ")",
+			"plugins=(\n)",
+			"source $ZSH/oh-my-zsh.sh",
+			fmt.Sprintf("source %q", clipboard),
+			fmt.Sprintf("source %q", filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh")),
+		)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_NONE
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered,
+			fmt.Sprintf("source %q", filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh")),
+			fmt.Sprintf("source %q", filepath.Join(history, "init.zsh")),
+			fmt.Sprintf("source %q", utility),
+			fmt.Sprintf("source %q", clipboard),
+			fmt.Sprintf("source %q", filepath.Join(autosuggestions, "zsh-autosuggestions.plugin.zsh")),
+		)
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_BUILTIN
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+		assertInOrder(t, rendered,
+			fmt.Sprintf("_dfctl_load %q", filepath.Join(env.OMZ(), "plugins", "git")),
+			fmt.Sprintf("_dfctl_load %q", history),
+			fmt.Sprintf("source %q", utility),
+			fmt.Sprintf("source %q", clipboard),
+			fmt.Sprintf("_dfctl_load %q", autosuggestions),
+			"unfunction _dfctl_load",
+		)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cfg.Plugins.Custom[2].Subdir = "lib"
+		_, err := renderWithFacts(cfg, testFacts)
+		assert.ErrorIs(t, err, ErrInvalidPluginSource)
+	})
+}
@@ -0,0 +1,307 @@
+package zsh
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+var (
+	ErrChecksumRequired   = fmt.Errorf("checksum required")
+	ErrChecksumMismatch   = fmt.Errorf("checksum mismatch")
+	ErrUnsupportedArchive = fmt.Errorf("unsupported archive")
+	ErrPathExists         = fmt.Errorf("already exists")
+)
+
+// httpClient downloads the archives of url plugins; the timeout spans the whole download
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// archiveMarker is the file marking a plugin as extracted from an archive by dfctl, so that Prune may remove it once it is orphaned
+const archiveMarker = ".dfctl-archive"
+
+// archiveExtensions are the supported archive formats, longest first
+var archiveExtensions = []string{".tar.gz", ".tar.bz2", ".tgz", ".tbz2", ".tar", ".zip"}
+
+// archiveName returns the file name of the archive at rawURL without its extension, e.g. plugin for https://example.com/plugin.tar.gz?v=1
+func archiveName(rawURL string) string {
+	name := path.Base(rawURL)
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+	}
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// linkLocal symlinks path to the directory dir, so that the plugin gets sourced in place
+func linkLocal(path, dir string) (err error) {
+	if dir, err = filepath.Abs(expandPath(dir, CurrentFacts())); err != nil {
+		return err
+	}
+	fs := factory.Default.Fs
+	info, err := fs.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	linker, ok := fs.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: dir, New: path, Err: afero.ErrNoSymlink}
+	}
+	if err = fs.MkdirAll(filepath.Dir(path), configDirPerm); err != nil {
+		return err
+	}
+	return linker.SymlinkIfPossible(dir, path)
+}
+
+// downloadArchive downloads the tar or zip archive at rawURL, verifies it against checksum and extracts it to path.
+// The single top-level directory most archives contain gets stripped.
+// Without a checksum nothing gets extracted; the error tells the checksum of the downloaded archive instead.
+// An existing path is never replaced or merged into; it has to be removed first.
+func downloadArchive(path, rawURL, checksum string) (err error) {
+	format, err := archiveFormat(rawURL)
+	if err != nil {
+		return err
+	}
+
+	fs := factory.Default.Fs
+	if err = checkNotExists(fs, path); err != nil {
+		return err
+	}
+	archive, err := afero.TempFile(fs, "", "dfctl-archive-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = archive.Close()
+		_ = fs.Remove(archive.Name())
+	}()
+
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download %s: %s", rawURL, resp.Status)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(archive, hash), resp.Body); err != nil {
+		return fmt.Errorf("unable to download %s: %w", rawURL, err)
+	}
+	if err = verifyChecksum(rawURL, checksum, "sha256:"+hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return err
+	}
+
+	if err = fs.MkdirAll(filepath.Dir(path), configDirPerm); err != nil {
+		return err
+	}
+	staging, err := afero.TempDir(fs, filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer fs.RemoveAll(staging)
+
+	if _, err = archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if format == ".zip" {
+		err = extractZip(fs, archive, staging)
+	} else {
+		err = extractTar(fs, archive, format, staging)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to extract %s: %w", rawURL, err)
+	}
+	root := stripTopLevelDir(fs, staging)
+	if err = afero.WriteFile(fs, filepath.Join(root, archiveMarker), []byte(rawURL+"\n"), configFilePerm); err != nil {
+		return err
+	}
+	// the path may have been created while downloading
+	if err = checkNotExists(fs, path); err != nil {
+		return err
+	}
+	return fs.Rename(root, path)
+}
+
+// checkNotExists fails with ErrPathExists if there is a file, directory or symlink at path
+func checkNotExists(fs afero.Fs, path string) (err error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		_, _, err = lstater.LstatIfPossible(path)
+	} else {
+		_, err = fs.Stat(path)
+	}
+	switch {
+	case err == nil:
+		return fmt.Errorf("unable to extract the archive to %s: %w; uninstall the plugin first", path, ErrPathExists)
+	case os.IsNotExist(err):
+		return nil
+	default:
+		return err
+	}
+}
+
+// archiveFormat returns the extension of the archive at rawURL
+func archiveFormat(rawURL string) (ext string, err error) {
+	name := strings.ToLower(path.Base(rawURL))
+	if u, err := url.Parse(rawURL); err == nil {
+		name = strings.ToLower(path.Base(u.Path))
+	}
+	for _, ext = range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return ext, nil
+		}
+	}
+	return "", fmt.Errorf("%w %s; expected one of %s", ErrUnsupportedArchive, rawURL, strings.Join(archiveExtensions, ", "))
+}
+
+// verifyChecksum compares the expected checksum, either sha256:<hex> or just the hex digest, with the actual one
+func verifyChecksum(rawURL, expected, actual string) error {
+	if expected == "" {
+		return fmt.Errorf("%w: set the checksum of %s to %s", ErrChecksumRequired, rawURL, actual)
+	}
+	if !strings.Contains(expected, ":") {
+		expected = "sha256:" + expected
+	}
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("%w: %s has checksum %s, expected %s", ErrChecksumMismatch, rawURL, actual, expected)
+	}
+	return nil
+}
+
+// stripTopLevelDir returns the only directory in dir, if dir contains nothing else, or else dir itself
+func stripTopLevelDir(fs afero.Fs, dir string) string {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+// extractPath returns where the archive entry name gets extracted to in dir; entries outside of dir are rejected
+func extractPath(dir, name string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if !within(dir, target) {
+		return "", fmt.Errorf("%w: entry %s is outside of the archive", ErrUnsupportedArchive, name)
+	}
+	return target, nil
+}
+
+// within reports whether path is dir or inside of it
+func within(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// extractTar extracts the tar archive, compressed according to format, to dir.
+// Symlinks are rejected: a chain of them could point outside of dir, even if each one of them points inside of it lexically.
+func extractTar(fs afero.Fs, r io.Reader, format, dir string) (err error) {
+	switch format {
+	case ".tar.gz", ".tgz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case ".tar.bz2", ".tbz2":
+		r = bzip2.NewReader(r)
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := extractPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = fs.MkdirAll(target, configDirPerm)
+		case tar.TypeReg:
+			err = extractFile(fs, target, os.FileMode(header.Mode).Perm(), archive)
+		case tar.TypeSymlink:
+			err = fmt.Errorf("%w: entry %s is a symlink", ErrUnsupportedArchive, header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts the zip archive to dir; symlinks are rejected like they are for tar archives
+func extractZip(fs afero.Fs, archive afero.File, dir string) error {
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, file := range r.File {
+		target, err := extractPath(dir, file.Name)
+		if err != nil {
+			return err
+		}
+		switch mode := file.Mode(); {
+		case mode.IsDir():
+			err = fs.MkdirAll(target, configDirPerm)
+		case mode&os.ModeSymlink != 0:
+			err = fmt.Errorf("%w: entry %s is a symlink", ErrUnsupportedArchive, file.Name)
+		default:
+			var content io.ReadCloser
+			if content, err = file.Open(); err != nil {
+				return err
+			}
+			err = extractFile(fs, target, mode.Perm(), content)
+			_ = content.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(fs afero.Fs, target string, perm os.FileMode, content io.Reader) (err error) {
+	if err = fs.MkdirAll(filepath.Dir(target), configDirPerm); err != nil {
+		return err
+	}
+	f, err := fs.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,202 @@
+package zsh
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// tarGzLinks returns a tar.gz archive of the symlinks, in order, followed by a file at path
+func tarGzLinks(t *testing.T, links [][2]string, path string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for _, link := range links {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: link[0], Linkname: link[1], Mode: 0777, Typeflag: tar.TypeSymlink}))
+	}
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: 4, Typeflag: tar.TypeReg}))
+	_, err := w.Write([]byte("evil"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func sha256sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestParsePluginKind(t *testing.T) {
+	for kind, expected := range map[string]RepoKind{"gh": PLUGIN_GITHUB, "git": PLUGIN_GIT, "omz": PLUGIN_OMZ, "local": PLUGIN_LOCAL, "URL": PLUGIN_URL} {
+		parsed, err := ParsePluginKind(kind)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsed)
+	}
+	_, err := ParsePluginKind("svn")
+	assert.ErrorIs(t, err, ErrUnsupportedPluginKind)
+	_, err = NewPlugin("zsh-users/zsh-autosuggestions", nil, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedPluginKind)
+}
+
+func TestNewPlugin_Sources(t *testing.T) {
+	p, err := NewPlugin("url:https://example.com/releases/vendor-plugin.tar.gz?v=2", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &Plugin{ID: "vendor-plugin", Name: "vendor-plugin", Repo: "https://example.com/releases/vendor-plugin.tar.gz?v=2", Kind: PLUGIN_URL, Enabled: true}, p)
+
+	p, err = NewPlugin("local:plugins/internal", nil, nil)
+	assert.NoError(t, err)
+	wd, _ := os.Getwd()
+	assert.Equal(t, filepath.Join(wd, "plugins", "internal"), p.Repo, "local paths get absolute")
+	assert.Equal(t, "internal", p.ID)
+}
+
+func TestPlugin_InstallLocal(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+	require.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	monorepo := filepath.Join(dir, "monorepo", "zsh", "internal")
+	require.NoError(t, os.MkdirAll(monorepo, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(monorepo, "internal.plugin.zsh"), []byte("v1"), 0644))
+
+	plugin := PluginFromSpec(&PluginSpec{ID: "internal", Name: "internal", Repo: monorepo, Kind: PLUGIN_LOCAL, Enabled: true})
+	result := plugin.Install()
+	require.NoError(t, result.Err)
+	assert.True(t, result.Installed)
+	assert.True(t, plugin.IsInstalled())
+
+	require.NoError(t, os.WriteFile(filepath.Join(monorepo, "internal.plugin.zsh"), []byte("v2"), 0644))
+	content, err := os.ReadFile(filepath.Join(plugin.Path(), "internal.plugin.zsh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(content), "the plugin is sourced in place")
+	assert.Equal(t, PLUGIN_LOCAL, MustLoad().Plugins.Custom[0].Kind)
+
+	assert.ErrorIs(t, plugin.Update(false).Err, ErrNotUpdatable)
+
+	_, err = Uninstall(plugin, false)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, plugin.Path())
+	assert.FileExists(t, filepath.Join(monorepo, "internal.plugin.zsh"), "uninstalling removes the link only")
+
+	missing := PluginFromSpec(&PluginSpec{ID: "missing", Name: "missing", Repo: filepath.Join(dir, "missing"), Kind: PLUGIN_LOCAL})
+	assert.True(t, os.IsNotExist(missing.Install().Err))
+}
+
+func TestPlugin_InstallURL(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+	require.NoError(t, Save(&ConfigSpec{Theme: "simple"}))
+
+	archives := map[string][]byte{
+		"/vendor.tar.gz": tarGz(t, map[string]string{"vendor-1.0/vendor.plugin.zsh": "tar", "vendor-1.0/functions/_vendor": "#compdef vendor"}),
+		"/vendor.zip":    zipped(t, map[string]string{"vendor.plugin.zsh": "zip"}),
+		"/evil.tar.gz":   tarGz(t, map[string]string{"../../evil.zsh": "evil"}),
+		"/links.tar.gz":  tarGzLinks(t, [][2]string{{"d/l", ".."}, {"d/l/l2", ".."}}, "d/l/l2/evil.zsh"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if archive, ok := archives[r.URL.Path]; ok {
+			_, _ = w.Write(archive)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	install := func(id, path, checksum string) (*Plugin, InstallResult) {
+		plugin := PluginFromSpec(&PluginSpec{ID: id, Name: id, Repo: server.URL + path, Kind: PLUGIN_URL, Checksum: checksum, Enabled: true})
+		return plugin, plugin.Install()
+	}
+
+	plugin, result := install("vendor", "/vendor.tar.gz", sha256sum(archives["/vendor.tar.gz"]))
+	require.NoError(t, result.Err)
+	content, err := os.ReadFile(filepath.Join(plugin.Path(), "vendor.plugin.zsh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", string(content), "the top-level directory gets stripped")
+	assert.FileExists(t, filepath.Join(plugin.Path(), "functions", "_vendor"))
+	assert.Equal(t, sha256sum(archives["/vendor.tar.gz"]), MustLoad().Plugins.Custom[0].Checksum)
+
+	err = downloadArchive(plugin.Path(), server.URL+"/vendor.zip", sha256sum(archives["/vendor.zip"]))
+	assert.ErrorIs(t, err, ErrPathExists, "an existing plugin is not extracted into")
+	content, err = os.ReadFile(filepath.Join(plugin.Path(), "vendor.plugin.zsh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", string(content))
+
+	plugin, result = install("vendor-zip", "/vendor.zip", sha256sum(archives["/vendor.zip"])[len("sha256:"):])
+	require.NoError(t, result.Err)
+	assert.FileExists(t, filepath.Join(plugin.Path(), "vendor.plugin.zsh"))
+
+	plugin, result = install("unverified", "/vendor.zip", "")
+	assert.ErrorIs(t, result.Err, ErrChecksumRequired)
+	assert.Contains(t, result.Err.Error(), sha256sum(archives["/vendor.zip"]), "the error tells the checksum to pin")
+	assert.NoDirExists(t, plugin.Path())
+
+	plugin, result = install("tampered", "/vendor.zip", sha256sum([]byte("tampered")))
+	assert.ErrorIs(t, result.Err, ErrChecksumMismatch)
+	assert.NoDirExists(t, plugin.Path())
+
+	_, result = install("evil", "/evil.tar.gz", sha256sum(archives["/evil.tar.gz"]))
+	assert.ErrorIs(t, result.Err, ErrUnsupportedArchive)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(env.Plugins()), "evil.zsh"))
+
+	_, result = install("links", "/links.tar.gz", sha256sum(archives["/links.tar.gz"]))
+	assert.ErrorIs(t, result.Err, ErrUnsupportedArchive, "symlinks are rejected, since chains of them escape the plugin")
+	assert.NoFileExists(t, filepath.Join(env.Plugins(), "evil.zsh"))
+
+	_, result = install("missing", "/missing.tar.gz", sha256sum(nil))
+	assert.Error(t, result.Err)
+	_, result = install("rar", "/vendor.rar", sha256sum(nil))
+	assert.ErrorIs(t, result.Err, ErrUnsupportedArchive)
+
+	entries, err := os.ReadDir(env.Plugins())
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"vendor", "vendor-zip"}, names, "failed installs leave nothing behind")
+}
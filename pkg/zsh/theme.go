@@ -4,7 +4,6 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/go-git/go-git/v5"
 	"github.com/rs/zerolog/log"
 
 	"github.com/alex-held/dfctl-kit/pkg/env"
@@ -12,16 +11,19 @@ import (
 
 type Theme struct {
 	*ThemeSpec
+	// Commit is the exact commit to install, e.g. from the lockfile; it takes precedence over Ref
+	Commit string
 }
 
 func (theme *Theme) SetEnabled(enable bool) error {
-	cfg := MustLoad()
-	if enable {
-		cfg.Theme = theme.Name
-		return Save(cfg)
-	}
-	cfg.Theme = Default().Theme
-	return Save(cfg)
+	return Update(func(cfg *ConfigSpec) error {
+		if enable {
+			cfg.Theme = theme.Name
+			return nil
+		}
+		cfg.Theme = Default().Theme
+		return nil
+	})
 }
 
 func (theme *Theme) IsEnabled() bool {
@@ -54,7 +56,12 @@ func (theme *Theme) Install() (res InstallResult) {
 		log.Debug().Msgf("plugin %s is already installed", theme.ID)
 		return InstallResult{Installed: false}
 	}
-	if _, err := git.PlainClone(path, false, &git.CloneOptions{URL: BuildRepositoryURI(theme.Repo, theme.Kind)}); err != nil {
+	if err := cloneAt(path, cloneOptions{
+		url:       BuildRepositoryURI(theme.Repo, theme.Kind),
+		ref:       theme.Ref,
+		commit:    theme.Commit,
+		CloneSpec: theme.Clone.Or(cloneDefaults()),
+	}); err != nil {
 		return InstallResult{Installed: false, Err: err}
 	}
 	return InstallResult{Installed: true}
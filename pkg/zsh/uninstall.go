@@ -0,0 +1,149 @@
+package zsh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
+)
+
+var ErrNotUninstallable = fmt.Errorf("not uninstallable")
+
+// Uninstall drops the spec of a custom plugin or theme from the config and removes its clone afterwards.
+// A theme which is currently set falls back to the default theme.
+// With dryRun nothing gets changed; removed is the clone that would be deleted, if it exists.
+func Uninstall(i Installable, dryRun bool) (removed string, err error) {
+	var drop func(cfg *ConfigSpec)
+	switch it := i.(type) {
+	case *Plugin:
+		if it.Kind == PLUGIN_OMZ {
+			return "", fmt.Errorf("%w: plugin %s is bundled with oh-my-zsh, disable it instead", ErrNotUninstallable, it.ID)
+		}
+		drop = func(cfg *ConfigSpec) {
+			kept := PluginsList{}
+			for _, spec := range cfg.Plugins.Custom {
+				if spec.ID != it.ID {
+					kept = append(kept, spec)
+				}
+			}
+			cfg.Plugins.Custom = kept
+		}
+	case *Theme:
+		if it.Kind == PLUGIN_OMZ {
+			return "", fmt.Errorf("%w: theme %s is bundled with oh-my-zsh", ErrNotUninstallable, it.ID)
+		}
+		drop = func(cfg *ConfigSpec) {
+			kept := ThemesSpec{}
+			for _, spec := range cfg.Themes {
+				if spec.ID != it.ID {
+					kept = append(kept, spec)
+				}
+			}
+			cfg.Themes = kept
+			if cfg.Theme == it.ID || cfg.Theme == it.Name {
+				cfg.Theme = Default().Theme
+			}
+		}
+	default:
+		return "", fmt.Errorf("%w: %s is bundled with oh-my-zsh, disable it instead", ErrNotUninstallable, i.Id())
+	}
+
+	fs := factory.Default.Fs
+	if exists, err := afero.DirExists(fs, i.Path()); err != nil {
+		return "", err
+	} else if exists {
+		removed = i.Path()
+	}
+	if dryRun {
+		return removed, nil
+	}
+
+	// the clone is kept when the config can not be updated; a clone left over once the spec got dropped can be pruned
+	err = Update(func(cfg *ConfigSpec) error {
+		drop(cfg)
+		return nil
+	})
+	if err != nil || removed == "" {
+		return "", err
+	}
+	if err = fs.RemoveAll(removed); err != nil {
+		return removed, fmt.Errorf("unable to remove %s: %w", i.Id(), err)
+	}
+	return removed, nil
+}
+
+// Orphan is a plugin or theme installed by dfctl which no plugin or theme of the config refers to
+type Orphan struct {
+	Path string
+	Kind InstallableKind
+}
+
+// Prune removes the orphaned plugins and themes and returns the removed ones.
+// Only what dfctl installs is considered, see installedByDfctl; plugins written by hand into the plugins dir are left alone.
+// With dryRun nothing gets removed.
+func Prune(dryRun bool) (orphans []Orphan, err error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for i := range cfg.Plugins.Custom {
+		referenced[PluginFromSpec(&cfg.Plugins.Custom[i]).Path()] = true
+	}
+	for i := range cfg.Themes {
+		referenced[(&Theme{ThemeSpec: &cfg.Themes[i]}).Path()] = true
+	}
+
+	fs := factory.Default.Fs
+	for dir, kind := range map[string]InstallableKind{env.Plugins(): PluginInstallableKind, env.Themes(): ThemeInstallableKind} {
+		entries, err := afero.ReadDir(fs, dir)
+		if err != nil {
+			if exists, _ := afero.DirExists(fs, dir); !exists {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !referenced[path] && installedByDfctl(fs, path, entry) {
+				orphans = append(orphans, Orphan{Path: path, Kind: kind})
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Path < orphans[j].Path
+	})
+
+	if dryRun {
+		return orphans, nil
+	}
+	for i, orphan := range orphans {
+		if err = fs.RemoveAll(orphan.Path); err != nil {
+			return orphans[:i], fmt.Errorf("unable to remove %s: %w", orphan.Path, err)
+		}
+	}
+	return orphans, nil
+}
+
+// installedByDfctl reports whether the entry of the plugins or themes dir at path looks like dfctl installed it:
+// a git clone, an archive extracted by dfctl or a link to a local directory which no longer exists
+func installedByDfctl(fs afero.Fs, path string, entry os.FileInfo) bool {
+	switch {
+	case entry.Mode()&os.ModeSymlink != 0:
+		_, err := fs.Stat(path)
+		return os.IsNotExist(err)
+	case entry.IsDir():
+		for _, marker := range []string{".git", archiveMarker} {
+			if exists, _ := afero.Exists(fs, filepath.Join(path, marker)); exists {
+				return true
+			}
+		}
+	}
+	return false
+}
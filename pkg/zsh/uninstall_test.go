@@ -0,0 +1,113 @@
+package zsh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+)
+
+func TestUninstall(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	commitFile(t, origin, "plugin.zsh", "v1")
+
+	theme := ThemeSpec{ID: "theme", Name: "theme", Repo: filepath.Join(dir, "origin"), Kind: PLUGIN_GIT}
+	require.NoError(t, Save(&ConfigSpec{Theme: "theme", Themes: ThemesSpec{theme}}))
+	plugin := PluginFromSpec(&PluginSpec{ID: "plugin", Name: "plugin", Repo: filepath.Join(dir, "origin"), Kind: PLUGIN_GIT, Enabled: true})
+	require.NoError(t, plugin.Install().Err)
+	require.NoError(t, (&Theme{ThemeSpec: &theme}).Install().Err)
+
+	removed, err := Uninstall(plugin, true)
+	assert.NoError(t, err)
+	assert.Equal(t, plugin.Path(), removed)
+	assert.DirExists(t, plugin.Path(), "dry runs remove nothing")
+	assert.Len(t, MustLoad().Plugins.Custom, 1)
+
+	valid, err := os.ReadFile(ConfigFile())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(ConfigFile(), []byte("theme: [\n"), 0644))
+	_, err = Uninstall(plugin, false)
+	assert.Error(t, err)
+	assert.DirExists(t, plugin.Path(), "the clone is kept when the config can not be updated")
+	require.NoError(t, os.WriteFile(ConfigFile(), valid, 0644))
+
+	removed, err = Uninstall(plugin, false)
+	assert.NoError(t, err)
+	assert.Equal(t, plugin.Path(), removed)
+	assert.NoDirExists(t, plugin.Path())
+	assert.Empty(t, MustLoad().Plugins.Custom)
+
+	removed, err = Uninstall(&Theme{ThemeSpec: &theme}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(env.Themes(), "theme"), removed)
+	cfg := MustLoad()
+	assert.Empty(t, cfg.Themes)
+	assert.Equal(t, Default().Theme, cfg.Theme, "the default theme replaces the uninstalled one")
+
+	_, err = Uninstall(&OMZPlugin{ID: "git"}, false)
+	assert.ErrorIs(t, err, ErrNotUninstallable)
+	_, err = Uninstall(&Plugin{ID: "git", Name: "git", Kind: PLUGIN_OMZ}, false)
+	assert.ErrorIs(t, err, ErrNotUninstallable)
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	env.Overrides.Home = filepath.Join(dir, "home")
+	env.Overrides.ConfigFile = filepath.Join(dir, "home", "dfctl.yaml")
+	defer env.ClearOverrides()
+	require.NoError(t, Save(&ConfigSpec{
+		Theme:   "simple",
+		Plugins: PluginsSpec{Custom: PluginsList{{ID: "kept", Name: "kept", Repo: "user/kept", Kind: PLUGIN_GITHUB, Enabled: true}}},
+	}))
+
+	for _, clone := range []string{filepath.Join(env.Plugins(), "kept"), filepath.Join(env.Plugins(), "orphan"), filepath.Join(env.Themes(), "orphan")} {
+		_, err := git.PlainInit(clone, false)
+		require.NoError(t, err)
+	}
+	handwritten := filepath.Join(env.Plugins(), "handwritten")
+	require.NoError(t, os.MkdirAll(handwritten, 0755))
+	linked := filepath.Join(env.Plugins(), "linked")
+	require.NoError(t, os.Symlink(handwritten, linked))
+	archive := filepath.Join(env.Plugins(), "archive")
+	require.NoError(t, os.MkdirAll(archive, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(archive, archiveMarker), []byte("https://example.com/archive.tar.gz\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "removed"), filepath.Join(env.Plugins(), "dangling")))
+
+	expected := []Orphan{
+		{Path: archive, Kind: PluginInstallableKind},
+		{Path: filepath.Join(env.Plugins(), "dangling"), Kind: PluginInstallableKind},
+		{Path: filepath.Join(env.Plugins(), "orphan"), Kind: PluginInstallableKind},
+		{Path: filepath.Join(env.Themes(), "orphan"), Kind: ThemeInstallableKind},
+	}
+	orphans, err := Prune(true)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, orphans)
+	assert.DirExists(t, expected[2].Path, "dry runs remove nothing")
+
+	orphans, err = Prune(false)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, orphans)
+	for _, orphan := range expected {
+		_, err = os.Lstat(orphan.Path)
+		assert.True(t, os.IsNotExist(err), orphan.Path)
+	}
+	assert.DirExists(t, filepath.Join(env.Plugins(), "kept"))
+	assert.DirExists(t, handwritten, "plugins written by hand are left alone")
+	_, err = os.Lstat(linked)
+	assert.NoError(t, err, "links to existing directories are left alone")
+
+	orphans, err = Prune(false)
+	assert.NoError(t, err)
+	assert.Empty(t, orphans)
+}
@@ -0,0 +1,170 @@
+package zsh
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var ErrDirtyWorktree = fmt.Errorf("worktree has uncommitted changes")
+var ErrNotFastForward = fmt.Errorf("local commits diverged from upstream, unable to fast-forward")
+var ErrNoUpstream = fmt.Errorf("checkout is not on a branch tracking a remote")
+var ErrNotUpdatable = fmt.Errorf("not updatable")
+var ErrPinned = fmt.Errorf("checkout is pinned to a tag or commit")
+
+// UpdateResult reports the commits an installed plugin or theme was updated from and to
+type UpdateResult struct {
+	From    plumbing.Hash
+	To      plumbing.Hash
+	Skipped bool
+	Err     error
+}
+
+// Updated returns whether the checkout moved to a new commit
+func (res UpdateResult) Updated() bool {
+	return res.Err == nil && !res.Skipped && res.From != res.To
+}
+
+// Range returns the abbreviated `old..new` commits of the update
+func (res UpdateResult) Range() string {
+	return shortHash(res.From) + ".." + shortHash(res.To)
+}
+
+func shortHash(hash plumbing.Hash) string {
+	return hash.String()[:7]
+}
+
+// Updatable is implemented by installables whose checkout can be updated from its remote
+type Updatable interface {
+	Installable
+	Update(force bool) (result UpdateResult)
+}
+
+func (p *Plugin) Update(force bool) (result UpdateResult) {
+	switch p.Kind {
+	case PLUGIN_OMZ:
+		return UpdateResult{Skipped: true, Err: fmt.Errorf("%w: plugin %s is bundled with oh-my-zsh", ErrNotUpdatable, p.ID)}
+	case PLUGIN_LOCAL:
+		return UpdateResult{Skipped: true, Err: fmt.Errorf("%w: plugin %s is sourced in place from %s", ErrNotUpdatable, p.ID, p.Repo)}
+	case PLUGIN_URL:
+		return UpdateResult{Skipped: true, Err: fmt.Errorf("%w: plugin %s is pinned by the checksum of its archive", ErrNotUpdatable, p.ID)}
+	}
+	return UpdateRepository(p.Path(), force)
+}
+
+func (theme *Theme) Update(force bool) (result UpdateResult) {
+	if theme.Kind == PLUGIN_OMZ {
+		return UpdateResult{Skipped: true, Err: fmt.Errorf("%w: theme %s is bundled with oh-my-zsh", ErrNotUpdatable, theme.ID)}
+	}
+	return UpdateRepository(theme.Path(), force)
+}
+
+// UpdateRepository fetches the upstream of the branch checked out at path and fast-forwards it.
+//
+// Checkouts with uncommitted changes or untracked files are skipped unless force is set,
+// in which case those get discarded. Files ignored by the repository, e.g. compiled .zwc files, are kept.
+func UpdateRepository(path string, force bool) (result UpdateResult) {
+	repo, err := openRepository(path)
+	if err != nil {
+		return UpdateResult{Err: err}
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return UpdateResult{Err: err}
+	}
+	result = UpdateResult{From: head.Hash(), To: head.Hash()}
+	if !head.Name().IsBranch() {
+		result.Skipped, result.Err = true, ErrPinned
+		return result
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if !status.IsClean() && !force {
+		result.Skipped, result.Err = true, ErrDirtyWorktree
+		return result
+	}
+
+	upstream, err := upstreamOf(repo, head)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err = repo.Fetch(&git.FetchOptions{RemoteName: upstream.remote}); err != nil && err != git.NoErrAlreadyUpToDate {
+		result.Err = err
+		return result
+	}
+
+	ref, err := repo.Reference(upstream.ref, true)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if ref.Hash() == head.Hash() {
+		return result
+	}
+
+	current, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	next, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if ok, err := current.IsAncestor(next); err != nil || !ok {
+		result.Err = ErrNotFastForward
+		if err != nil {
+			result.Err = err
+		}
+		return result
+	}
+
+	mode := git.MergeReset
+	if force {
+		mode = git.HardReset
+	}
+	if err = worktree.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: mode}); err != nil {
+		result.Err = err
+		return result
+	}
+	result.To = ref.Hash()
+	return result
+}
+
+type upstream struct {
+	remote string
+	ref    plumbing.ReferenceName
+}
+
+// upstreamOf returns the remote tracking branch of the checked out branch, falling back to the same branch of origin
+func upstreamOf(repo *git.Repository, head *plumbing.Reference) (u upstream, err error) {
+	if !head.Name().IsBranch() {
+		return u, ErrNoUpstream
+	}
+	branch := head.Name().Short()
+	u = upstream{remote: git.DefaultRemoteName, ref: plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch)}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return u, err
+	}
+	if b, ok := cfg.Branches[branch]; ok && b.Remote != "" && b.Merge.IsBranch() {
+		u = upstream{remote: b.Remote, ref: plumbing.NewRemoteReferenceName(b.Remote, b.Merge.Short())}
+	}
+	if _, ok := cfg.Remotes[u.remote]; !ok {
+		return u, ErrNoUpstream
+	}
+	return u, nil
+}
@@ -0,0 +1,112 @@
+package zsh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commitFile(t *testing.T, repo *git.Repository, name, content string) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(worktree.Filesystem.Root(), name), []byte(content), 0644))
+	_, err = worktree.Add(name)
+	require.NoError(t, err)
+	hash, err := worktree.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "dfctl", Email: "dfctl@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return hash
+}
+
+func TestUpdateRepository(t *testing.T) {
+	dir := t.TempDir()
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	commitFile(t, origin, ".gitignore", "*.zwc")
+	initial := commitFile(t, origin, "plugin.zsh", "v1")
+
+	path := filepath.Join(dir, "plugin")
+	_, err = git.PlainClone(path, false, &git.CloneOptions{URL: filepath.Join(dir, "origin")})
+	require.NoError(t, err)
+
+	result := UpdateRepository(path, false)
+	assert.NoError(t, result.Err)
+	assert.False(t, result.Updated(), "already up to date")
+
+	latest := commitFile(t, origin, "plugin.zsh", "v2")
+	result = UpdateRepository(path, false)
+	assert.NoError(t, result.Err)
+	assert.True(t, result.Updated())
+	assert.Equal(t, initial, result.From)
+	assert.Equal(t, latest, result.To)
+	assert.Equal(t, initial.String()[:7]+".."+latest.String()[:7], result.Range())
+	content, _ := os.ReadFile(filepath.Join(path, "plugin.zsh"))
+	assert.Equal(t, "v2", string(content))
+
+	// ignored files, e.g. compiled by zsh, do not make the worktree dirty
+	assert.NoError(t, os.WriteFile(filepath.Join(path, "plugin.zsh.zwc"), nil, 0644))
+	latest = commitFile(t, origin, "plugin.zsh", "v2.1")
+	result = UpdateRepository(path, false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, latest, result.To)
+
+	latest = commitFile(t, origin, "plugin.zsh", "v3")
+	for name, content := range map[string]string{"plugin.zsh": "local change", "untracked.zsh": ""} {
+		assert.NoError(t, os.WriteFile(filepath.Join(path, name), []byte(content), 0644))
+		result = UpdateRepository(path, false)
+		assert.ErrorIs(t, result.Err, ErrDirtyWorktree, name)
+		assert.True(t, result.Skipped)
+		assert.False(t, result.Updated())
+	}
+
+	result = UpdateRepository(path, true)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, latest, result.To)
+	content, _ = os.ReadFile(filepath.Join(path, "plugin.zsh"))
+	assert.Equal(t, "v3", string(content))
+	assert.FileExists(t, filepath.Join(path, "plugin.zsh.zwc"))
+
+	clone, err := git.PlainOpen(path)
+	require.NoError(t, err)
+	commitFile(t, clone, "local.zsh", "local commit")
+	commitFile(t, origin, "plugin.zsh", "v4")
+	result = UpdateRepository(path, true)
+	assert.ErrorIs(t, result.Err, ErrNotFastForward)
+	assert.False(t, result.Updated())
+}
+
+func TestUpdateRepository_Shallow(t *testing.T) {
+	dir := t.TempDir()
+	origin, err := git.PlainInit(filepath.Join(dir, "origin"), false)
+	require.NoError(t, err)
+	commitFile(t, origin, "plugin.zsh", "v1")
+	initial := commitFile(t, origin, "plugin.zsh", "v2")
+
+	path := filepath.Join(dir, "plugin")
+	require.NoError(t, cloneAt(path, cloneOptions{url: filepath.Join(dir, "origin"), CloneSpec: DefaultClone}))
+
+	commitFile(t, origin, "plugin.zsh", "v3")
+	latest := commitFile(t, origin, "plugin.zsh", "v4")
+	result := UpdateRepository(path, false)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, initial, result.From)
+	assert.Equal(t, latest, result.To)
+
+	repo, err := openRepository(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countCommits(t, repo), "only the new commits are fetched")
+}
+
+func TestPlugin_Update_OMZ(t *testing.T) {
+	result := (&Plugin{ID: "git", Kind: PLUGIN_OMZ}).Update(false)
+	assert.True(t, result.Skipped)
+	assert.ErrorIs(t, result.Err, ErrNotUpdatable)
+}
@@ -0,0 +1,88 @@
+package zsh
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Value is a config value which may only apply when its When condition holds.
+// Values without condition are written as plain scalars:
+//
+//	exports:
+//	  GOPATH: ${{ home }}/go
+//	  BROWSER:
+//	    value: open -a Safari
+//	    when: os == "darwin"
+type Value struct {
+	Value string `yaml:"value"`
+	When  string `yaml:"when,omitempty"`
+}
+
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*v = Value{Value: node.Value}
+		return nil
+	}
+	type plain Value
+	return node.Decode((*plain)(v))
+}
+
+func (v Value) MarshalYAML() (interface{}, error) {
+	if v.When == "" {
+		return v.Value, nil
+	}
+	type plain Value
+	return plain(v), nil
+}
+
+// ValueList is a list of conditional values
+type ValueList []Value
+
+// ValueListOf creates a ValueList without conditions from the plain values
+func ValueListOf(values ...string) (list ValueList) {
+	for _, value := range values {
+		list = append(list, Value{Value: value})
+	}
+	return list
+}
+
+// Resolve evaluates the conditions and interpolates the values of all entries against facts.
+// Entries whose condition does not hold are omitted.
+func (values Values) Resolve(facts Facts) (resolved KeyValues, err error) {
+	for _, value := range values {
+		ok, err := facts.Eval(value.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition of %s: %w", value.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		interpolated, err := facts.Interpolate(value.Value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", value.Name, err)
+		}
+		resolved = append(resolved, KeyValue{Key: value.Name, Value: interpolated})
+	}
+	return resolved, nil
+}
+
+// Resolve evaluates the conditions and interpolates the values of all entries against facts.
+// Entries whose condition does not hold are omitted.
+func (list ValueList) Resolve(facts Facts) (resolved []string, err error) {
+	for _, value := range list {
+		ok, err := facts.Eval(value.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition of %s: %w", value.Value, err)
+		}
+		if !ok {
+			continue
+		}
+		interpolated, err := facts.Interpolate(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s: %w", value.Value, err)
+		}
+		resolved = append(resolved, interpolated)
+	}
+	return resolved, nil
+}
@@ -1,65 +1,202 @@
 package zsh
 
 import (
+	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/ahmetb/go-linq"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
 
 	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
 )
 
-func Source() (rendered string, err error) {
+// RenderOptions configure how the zshrc gets rendered
+type RenderOptions struct {
+	// Shell is the shell to render the startup file for; defaults to zsh
+	Shell string
+	// Templates are additional template files, applied after the ones in TemplatesDir
+	Templates []string
+	// Profile is the file the instrumented zshrc logs its load times to, see RunProfile
+	Profile string
+}
+
+type RenderOption func(o *RenderOptions)
+
+// WithShell renders the startup file for shell instead of zsh, see Shells
+func WithShell(shell string) RenderOption {
+	return func(o *RenderOptions) {
+		o.Shell = shell
+	}
+}
+
+// WithTemplate applies the template file at path on top of the built-in template and the user overrides
+func WithTemplate(path string) RenderOption {
+	return func(o *RenderOptions) {
+		o.Templates = append(o.Templates, path)
+	}
+}
+
+func Source(opts ...RenderOption) (rendered string, err error) {
 	cfg, err := Load()
 	if err != nil {
 		return "", err
 	}
 
-	rendered, err = render(cfg)
+	rendered, err = render(cfg, opts...)
 	return rendered, err
 }
 
-func render(cfg *ConfigSpec) (rendered string, err error) {
-	tpl := template.New("zshrc")
-	parse, err := tpl.Parse(tmpl)
+func render(cfg *ConfigSpec, opts ...RenderOption) (rendered string, err error) {
+	return renderWithFacts(cfg, CurrentFacts(), opts...)
+}
+
+// TemplatesDir returns the directory containing the user overrides of the zshrc template.
+//
+// Every *.tmpl file in it is parsed on top of the built-in template, in lexical order.
+// Files consisting of `{{ define "<block>" }}` actions only replace the named blocks
+// (globals, exports, path, pre-source, omz-config, plugins, framework, user-config, aliases, options, post-source);
+// any other content replaces the whole template.
+func TemplatesDir() string {
+	return filepath.Join(filepath.Dir(ConfigFile()), "templates")
+}
+
+// renderData is the data the zshrc template gets executed with
+type renderData struct {
+	Framework  Framework
+	OMZ_HOME   string
+	ZSH_CUSTOM string
+	Theme      string
+	Plugins    []string
+	// PluginOrder are the names of the eagerly loaded plugins oh-my-zsh loads, in load order,
+	// up to the first plugin selecting a subdir or file, which oh-my-zsh cannot load by name
+	PluginOrder []string
+	// PluginDirs are the directories of the eagerly loaded plugins, added to fpath
+	PluginDirs []string
+	// PluginInits are the init files sourced directly: all of them without a framework, those of the plugins
+	// oh-my-zsh does not load by name with oh-my-zsh
+	PluginInits []string
+	// PluginSources are the eagerly loaded plugins of the builtin framework
+	PluginSources []PluginSource
+	// LazyPluginDirs are the directories of the plugins which are not loaded eagerly, added to fpath for their completions
+	LazyPluginDirs []string
+	// DeferredInits are the init files of the plugins loaded once the first prompt is shown
+	DeferredInits []string
+	// CommandPlugins are the plugins loaded the first time one of their commands runs
+	CommandPlugins []CommandPlugin
+	ThemeDir       string
+	ThemeFile      string
+	OMZPlugins     []string
+	Paths          []string
+	// PrependPaths are added in front of $PATH, in their configured order
+	PrependPaths []string
+	Exports      KeyValues
+	Aliases      KeyValues
+	PostSources  []string
+	PreSources   []string
+	UserConfigs  KeyValues
+	OMZConfigs   KeyValues
+	ZshOptions   Options
+
+	// Config is the full config, e.g. to access values the built-in template does not use
+	Config *ConfigSpec
+	// Facts describe the machine the zshrc gets rendered on
+	Facts Facts
+}
+
+func templateFuncs(facts Facts) template.FuncMap {
+	return template.FuncMap{
+		"quote":  quote,
+		"dquote": dquote,
+		"qpath":  qpath,
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"env":  facts.Env.Get,
+		"os":   func() string { return facts.OS },
+		"fact": facts.Lookup,
+	}
+}
+
+func parseTemplate(facts Facts, opts *RenderOptions) (tpl *template.Template, err error) {
+	tpl, err = template.New("zshrc").
+		Funcs(templateFuncs(facts)).
+		Funcs(template.FuncMap{"section": profileSection(opts.Profile)}).
+		Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := afero.Glob(factory.Default.Fs, filepath.Join(TemplatesDir(), "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(overrides)
+
+	for _, path := range append(overrides, opts.Templates...) {
+		content, err := afero.ReadFile(factory.Default.Fs, path)
+		if err != nil {
+			return nil, err
+		}
+		// parsing under the name of the main template replaces it unless the file only defines blocks
+		if _, err = tpl.New(tpl.Name()).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", path, err)
+		}
+	}
+	return tpl.Lookup(tpl.Name()), nil
+}
+
+func newRenderOptions(opts ...RenderOption) *RenderOptions {
+	o := &RenderOptions{Shell: "zsh"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func renderWithFacts(cfg *ConfigSpec, facts Facts, opts ...RenderOption) (rendered string, err error) {
+	o := newRenderOptions(opts...)
+	renderer, err := RendererFor(o.Shell)
+	if err != nil {
+		return rendered, err
+	}
+	return renderer.Render(cfg, facts, o)
+}
+
+// zshRenderer renders the .zshrc from the built-in template and the user overrides
+type zshRenderer struct{}
+
+func (zshRenderer) Render(cfg *ConfigSpec, facts Facts, opts *RenderOptions) (rendered string, err error) {
+	data, err := newRenderData(cfg, facts)
 	if err != nil {
 		return rendered, err
 	}
+	if err = data.resolvePlugins(cfg); err != nil {
+		return rendered, err
+	}
 
-	data := struct {
-		OMZ_HOME    string
-		Theme       string
-		Plugins     []string
-		OMZPlugins  []string
-		Paths       []string
-		Exports     map[string]string
-		Aliases     map[string]string
-		PostSources []string
-		PreSources  []string
-		UserConfigs map[string]string
-		OMZConfigs  map[string]string
-		ZshOptions  map[string]bool
-	}{
-		OMZ_HOME:    env.OMZ(),
-		Theme:       cfg.Theme,
-		Paths:       cfg.Configs.Paths,
-		Exports:     cfg.Exports,
-		Aliases:     cfg.Aliases,
-		PostSources: cfg.Source.Post,
-		PreSources:  cfg.Source.Pre,
-		UserConfigs: cfg.Configs.User,
-		ZshOptions:  cfg.Configs.ZshOptions,
-		OMZConfigs:  cfg.Configs.OMZ,
-		OMZPlugins:  cfg.Plugins.OMZ.PluginIDs(),
-	}
-
-	linq.From(cfg.Plugins.Custom).SelectT(func(spec PluginSpec) string {
-		return spec.ID
-	}).ToSlice(&data.Plugins)
+	tpl, err := parseTemplate(facts, opts)
+	if err != nil {
+		return rendered, err
+	}
+	if rendered, err = execute(tpl, data); err != nil {
+		return rendered, err
+	}
+	if opts.Profile != "" {
+		rendered = instrument(rendered, opts.Profile)
+	}
+	return rendered, nil
+}
 
+func execute(tpl *template.Template, data *renderData) (rendered string, err error) {
 	sb := &strings.Builder{}
 
-	if err := parse.Execute(sb, &data); err != nil {
+	if err := tpl.Execute(sb, data); err != nil {
 		return rendered, err
 	}
 
@@ -67,103 +204,363 @@ func render(cfg *ConfigSpec) (rendered string, err error) {
 	return rendered, nil
 }
 
-var tmpl = `
+// newRenderData validates the names and resolves the values of cfg against facts
+func newRenderData(cfg *ConfigSpec, facts Facts) (data *renderData, err error) {
+	if err = validateNames(cfg); err != nil {
+		return nil, err
+	}
+
+	data = &renderData{
+		OMZ_HOME:   env.OMZ(),
+		ZSH_CUSTOM: filepath.Join(env.OMZ(), "custom"),
+		Theme:      cfg.Theme,
+		Aliases:    cfg.Aliases,
+		ZshOptions: cfg.Configs.ZshOptions,
+		OMZConfigs: cfg.Configs.OMZ,
+		OMZPlugins: cfg.Plugins.OMZ.PluginIDs(),
+		Config:     cfg,
+		Facts:      facts,
+	}
+
+	if data.PrependPaths, data.Paths, err = cfg.Configs.Paths.Resolve(facts); err != nil {
+		return nil, err
+	}
+	if data.Exports, err = cfg.Exports.Resolve(facts); err != nil {
+		return nil, err
+	}
+	if data.UserConfigs, err = cfg.Configs.User.Resolve(facts); err != nil {
+		return nil, err
+	}
+	if data.PreSources, err = cfg.Source.Pre.Resolve(facts); err != nil {
+		return nil, err
+	}
+	if data.PostSources, err = cfg.Source.Post.Resolve(facts); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// resolvePlugins resolves how the framework of cfg loads the enabled plugins and the theme
+func (data *renderData) resolvePlugins(cfg *ConfigSpec) (err error) {
+	if data.Framework, err = ParseFramework(string(cfg.Framework)); err != nil {
+		return err
+	}
+
+	linq.From(cfg.Plugins.Custom).
+		WhereT(func(spec PluginSpec) bool { return spec.Enabled }).
+		SelectT(func(spec PluginSpec) string { return PluginFromSpec(&spec).PluginName() }).
+		ToSlice(&data.Plugins)
+
+	ordered, err := LoadOrder(cfg)
+	if err != nil {
+		return err
+	}
+	// oh-my-zsh loads plugins by name, but cannot load a subdir or file of them: the first plugin selecting one
+	// and all plugins ordered after it get sourced right after oh-my-zsh instead, so that the load order holds
+	sourced := data.Framework != FRAMEWORK_OMZ
+	var eager []*Plugin
+	for _, p := range ordered {
+		if err = p.validateSource(); err != nil {
+			return err
+		}
+		if !p.Load.IsEager() {
+			data.resolveLazyPlugin(p)
+			continue
+		}
+		if sourced = sourced || p.selectsSource(); !sourced {
+			data.PluginOrder = append(data.PluginOrder, p.PluginName())
+			// oh-my-zsh adds its own plugins to fpath
+			if p.Kind != PLUGIN_OMZ {
+				data.PluginDirs = append(data.PluginDirs, p.Dir())
+			}
+			continue
+		}
+		eager = append(eager, p)
+		if !p.IsSnippet() {
+			data.PluginDirs = append(data.PluginDirs, p.Dir())
+		}
+	}
+
+	if data.Framework == FRAMEWORK_OMZ {
+		// oh-my-zsh loads the plugins of PluginOrder and the theme itself
+		for _, p := range eager {
+			data.appendInit(p)
+		}
+		return nil
+	}
+
+	data.ThemeDir, data.ThemeFile = themeLocation(cfg)
+
+	if data.Framework == FRAMEWORK_BUILTIN {
+		for _, p := range eager {
+			source := PluginSource{Dir: p.Dir()}
+			if p.selectedInit() != "" {
+				var ok bool
+				if source.Init, ok = p.initFile(); !ok {
+					log.Warn().Msgf("skipping plugin %s; %s not found", p.ID, p.describeSource())
+					continue
+				}
+			}
+			data.PluginSources = append(data.PluginSources, source)
+		}
+	}
+
+	if data.Framework == FRAMEWORK_NONE {
+		for _, p := range eager {
+			data.appendInit(p)
+		}
+		if data.ThemeDir != "" {
+			data.ThemeFile, _ = findInitFile(data.ThemeDir)
+			data.ThemeDir = ""
+		}
+		if data.ThemeFile == "" && cfg.Theme != "" {
+			log.Warn().Msgf("skipping theme %s; no theme file found", cfg.Theme)
+		}
+	}
+	return nil
+}
+
+// appendInit adds the init file of p to the files sourced directly, skipping plugins without one
+func (data *renderData) appendInit(p *Plugin) {
+	if init, ok := p.initFile(); ok {
+		data.PluginInits = append(data.PluginInits, init)
+		return
+	}
+	log.Warn().Msgf("skipping plugin %s; no init file found in %s", p.ID, p.describeSource())
+}
+
+// PluginSource is a plugin loaded by the builtin framework, either from its selected Init file or from the init file found in Dir
+type PluginSource struct {
+	Dir  string
+	Init string
+}
+
+// CommandPlugin is a plugin loaded by the first run of one of its commands
+type CommandPlugin struct {
+	Init     string
+	Commands []string
+}
+
+// resolveLazyPlugin finds the init file of a plugin which is not loaded eagerly, whatever the framework,
+// since neither oh-my-zsh nor the loader of the builtin framework load plugins lazily
+func (data *renderData) resolveLazyPlugin(p *Plugin) {
+	if !p.IsSnippet() {
+		data.LazyPluginDirs = append(data.LazyPluginDirs, p.Dir())
+	}
+	init, ok := p.initFile()
+	if !ok {
+		log.Warn().Msgf("skipping plugin %s; no init file found in %s", p.ID, p.describeSource())
+		return
+	}
+	if commands := p.Load.Commands(); len(commands) > 0 {
+		data.CommandPlugins = append(data.CommandPlugins, CommandPlugin{Init: init, Commands: commands})
+		return
+	}
+	data.DeferredInits = append(data.DeferredInits, init)
+}
+
+var tmpl = `{{ section "globals" }}{{ block "globals" . }}
 ###############################################################################
 # GLOBALS
 ##
-export ZSH="{{ .OMZ_HOME }}"
-
-
+{{- if eq .Framework "omz" }}
+export ZSH={{ dquote .OMZ_HOME }}
+export ZSH_CUSTOM={{ dquote .ZSH_CUSTOM }}
+{{- end }}
+{{ end }}
+{{ section "exports" }}{{ block "exports" . }}
 ###############################################################################
 # EXPORTS
 ##
 {{- if .Exports }}
-{{- range $key, $val := .Exports }}
-export {{ $key }}="{{ $val -}}"
+{{- range $export := .Exports }}
+export {{ $export.Key }}={{ dquote $export.Value -}}
 {{- end -}}
 {{ end }}
-
-
+{{ end }}
+{{ section "path" }}{{ block "path" . }}
 ###############################################################################
 # PATH
 ##
 typeset -U path
+{{- if .PrependPaths }}
+path=(
+	{{- range $path := .PrependPaths }}
+	{{ qpath $path -}}
+	{{ end }}
+	$path
+)
+{{- end }}
 {{- if .Paths }}
 path+=(
 	{{- range $path := .Paths }}
-	{{ $path -}}
+	{{ qpath $path -}}
 	{{ end }}
 )
 {{ end }}
-
-
+{{ end }}
+{{ section "pre-source" }}{{ block "pre-source" . }}
+###############################################################################
+# PRE SOURCE
+##
+{{- if .PreSources }}
+{{- range $script := .PreSources }}
+[[ ! -f {{ qpath $script }} ]] || source {{ qpath $script }}
+{{- end -}}
+{{ end }}
+{{ end }}
+{{ section "omz-config" }}{{ block "omz-config" . }}
+{{- if eq .Framework "omz" }}
 ###############################################################################
 # OMZ CONFIG
 ##
-ZSH_THEME="{{ .Theme }}"
+ZSH_THEME={{ dquote .Theme }}
 
 {{- if .OMZConfigs }}
-{{- range $option, $value := .OMZConfigs }}
-{{ $option }}="{{ $value }}"
+{{- range $config := .OMZConfigs }}
+{{ $config.Key }}={{ dquote $config.Value }}
 {{ end -}}
 {{ end }}
-
-
+{{- end }}
+{{ end }}
+{{ section "plugins" }}{{ block "plugins" . }}
 ###############################################################################
 # PLUGINS
 ##
-plugins=(
-		# OMZ
-		{{- range $omz := .OMZPlugins }}
-		{{ $omz -}}
-		{{ end }}
+typeset -U fpath
+{{- if or .PluginDirs .LazyPluginDirs }}
+fpath+=(
+	{{- range $dir := .PluginDirs }}
+	{{ qpath $dir -}}
+	{{ end }}
+	{{- range $dir := .LazyPluginDirs }}
+	{{ qpath $dir -}}
+	{{ end }}
+)
+{{- end }}
+{{- if eq .Framework "omz" }}
 
-		# CUSTOM
-        {{- range $plugin := .Plugins }}
-		{{ $plugin -}}
+plugins=(
+		{{- range $plugin := .PluginOrder }}
+		{{ quote $plugin -}}
 		{{ end }}
 )
-
-
+{{- end }}
+{{ end }}
+{{ section "framework" }}{{ block "framework" . }}
+{{- if eq .Framework "omz" }}
 source $ZSH/oh-my-zsh.sh
+{{- range $init := .PluginInits }}
+source {{ qpath $init }}
+{{- end }}
+{{- else }}
+autoload -Uz compinit && compinit
+{{- if eq .Framework "builtin" }}
+
+_dfctl_load() {
+	local dir=$1 name=${1:t} init
+	for init in $dir/$name.plugin.zsh(N) $dir/$name.zsh-theme(N) $dir/init.zsh(N) $dir/$name.zsh(N) $dir/$name.sh(N) $dir/*.plugin.zsh(N) $dir/*.zsh-theme(N); do
+		source $init
+		return
+	done
+	print -u2 "dfctl: no init file found in $dir"
+}
+{{ range $plugin := .PluginSources }}
+{{- if $plugin.Init }}
+source {{ qpath $plugin.Init }}
+{{- else }}
+_dfctl_load {{ qpath $plugin.Dir }}
+{{- end }}
+{{- end }}
+{{- if .ThemeDir }}
+_dfctl_load {{ qpath .ThemeDir }}
+{{- end }}
+unfunction _dfctl_load
+{{- else }}
+{{- range $init := .PluginInits }}
+source {{ qpath $init }}
+{{- end }}
+{{- end }}
+{{- if .ThemeFile }}
+source {{ qpath .ThemeFile }}
+{{- end }}
+{{- end }}
+{{- range $plugin := .CommandPlugins }}
+{{- range $command := $plugin.Commands }}
+
+{{ $command }}() {
+	unfunction {{ join " " $plugin.Commands }}
+	source {{ qpath $plugin.Init }}
+	{{ $command }} "$@"
+}
+{{- end }}
+{{- end }}
+{{- if .DeferredInits }}
 
-
+_dfctl_deferred=(
+	{{- range $init := .DeferredInits }}
+	{{ qpath $init -}}
+	{{ end }}
+)
+_dfctl_load_deferred() {
+	local fd=$1 init
+	if [[ -n $fd ]]; then
+		zle -F $fd
+		exec {fd}<&-
+	fi
+	for init in $_dfctl_deferred; do
+		source $init
+	done
+	unset _dfctl_deferred
+	unfunction _dfctl_load_deferred
+}
+if [[ -o zle ]]; then
+	# /dev/null is readable right away, so zle runs the handler as soon as it waits for input after the first prompt
+	exec {_dfctl_deferred_fd}</dev/null
+	zle -F $_dfctl_deferred_fd _dfctl_load_deferred
+	unset _dfctl_deferred_fd
+else
+	_dfctl_load_deferred
+fi
+{{- end }}
+{{ end }}
+{{ section "user-config" }}{{ block "user-config" . }}
 ###############################################################################
 # USER CONFIG
 ##
 {{- if .UserConfigs }}
-{{- range $key, $val := .UserConfigs }}
-export {{ $key }}="{{ $val -}}"
+{{- range $config := .UserConfigs }}
+export {{ $config.Key }}={{ dquote $config.Value -}}
 {{- end -}}
 {{ end }}
-
-
+{{ end }}
+{{ section "aliases" }}{{ block "aliases" . }}
 ###############################################################################
 # ALIASES
 ##
 {{- if .Aliases }}
-{{- range $alias, $command := .Aliases }}
-alias {{ $alias }}="{{ $command }}"
+{{- range $alias := .Aliases }}
+alias {{ $alias.Key }}={{ quote $alias.Value }}
 {{- end -}}
 {{ end }}
-
-
+{{ end }}
+{{ section "options" }}{{ block "options" . }}
 ###############################################################################
 # OPTIONS
 ##
 {{- if .ZshOptions }}
-{{- range $option, $enabled := .ZshOptions }}
-{{ if $enabled }}setopt {{ $option -}} {{ else }}unsetopt {{ $option -}} {{ end -}}
+{{- range $option := .ZshOptions }}
+{{ if $option.Enabled }}setopt {{ $option.Name -}} {{ else }}unsetopt {{ $option.Name -}} {{ end -}}
 {{ end -}}
 {{ end }}
-
-
+{{ end }}
+{{ section "post-source" }}{{ block "post-source" . }}
 ###############################################################################
 # POST SOURCE
 ##
 {{- if .PostSources }}
 {{- range $script := .PostSources }}
-[[ ! -f {{ $script }} ]] || source {{ $script }}
+[[ ! -f {{ qpath $script }} ]] || source {{ qpath $script }}
 {{ end }}
 {{ end -}}
-`
+{{ end }}`
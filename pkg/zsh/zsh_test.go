@@ -2,15 +2,22 @@ package zsh
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/dfctl-kit/pkg/env"
+
+	"github.com/alex-held/dfctl/pkg/factory"
 )
 
 func TestRender(t *testing.T) {
 	cfg := &ConfigSpec{
 		Theme: "powerlevel10k/powerlevel10k",
-		Exports: map[string]string{
+		Exports: ValuesOf(map[string]string{
 			"GOPATH":                          "$HOME/go",
 			"GOBIN":                           "$HOME/go/bin",
 			"GOROOT":                          "$HOME/.devctl/sdks/go/current",
@@ -30,7 +37,7 @@ func TestRender(t *testing.T) {
 			"FZF_DEFAULT_COMMAND":             "rg --files --no-ignore --hidden --follow -g '!{.git,node_modules}/*' 2> /dev/null",
 			"FZF_DEFAULT_OPTS":                "--ansi --layout=default --info=inline --height=50% --multi --preview-window=right:50% --preview-window=sharp --preview-window=cycle --preview '([[ -f {} ]] && (bat --style=numbers --color=always --theme=gruvbox-dark --line-range :500 {} || cat {})) || ([[ -d {} ]] && (tree -C {} | less)) || echo {} 2> /dev/null | head -200' --prompt='λ -> ' --pointer='|>' --marker='✓' --bind 'ctrl-e:execute(nvim {} < /dev/tty > /dev/tty 2>&1)' > selected --bind 'ctrl-v:execute(code {+})'",
 			"FZF_CTRL_T_COMMAND":              "$FZF_DEFAULT_COMMAND",
-		},
+		}),
 		Plugins: PluginsSpec{
 			OMZ: OMZPluginList(
 				"ag",
@@ -110,7 +117,7 @@ func TestRender(t *testing.T) {
 				Kind: PLUGIN_GITHUB,
 			},
 		},
-		Aliases: map[string]string{
+		Aliases: KeyValuesOf(map[string]string{
 			"k":         "kubectl",
 			"ls":        "exa -b --links --long -a --git",
 			"l":         "exa -@ --git  -H -g -a --group-directories-first --long --modified",
@@ -125,14 +132,14 @@ func TestRender(t *testing.T) {
 			"egrep":     "egrep --color=auto",
 			"sudo":      "sudo ",
 			"flush":     "dscacheutil -flushcache && killall -HUP mDNSResponder",
-		},
+		}),
 		Source: SourceSpec{
-			Post: []string{
+			Post: ValueListOf(
 				"~/.p10k.zsh",
-			},
+			),
 		},
 		Configs: ConfigsSpec{
-			ZshOptions: map[string]bool{
+			ZshOptions: OptionsOf(map[string]bool{
 				"BEEP":             false,
 				"no_beep":          true,
 				"case_glob":        false,
@@ -157,18 +164,18 @@ func TestRender(t *testing.T) {
 				"always_to_end":    true,
 				"menu_complete":    true,
 				"COMPLETE_ALIASES": true,
-			},
-			User: map[string]string{
+			}),
+			User: ValuesOf(map[string]string{
 				"EDITOR": "vim",
 				"LANG":   "en_US.UTF-8",
-			},
-			Paths: []string{
+			}),
+			Paths: PathListOf(
 				"$GOBIN",
 				"$HOME/.devctl/sdks/go/current/bin",
-			},
-			OMZ: map[string]string{
+			),
+			OMZ: KeyValuesOf(map[string]string{
 				"ENABLE_CORRECTION": "true",
-			},
+			}),
 		},
 	}
 
@@ -183,3 +190,140 @@ func TestRender(t *testing.T) {
 
 	fmt.Println(rendered)
 }
+
+func TestRender_PluginsAndSources(t *testing.T) {
+	cfg := &ConfigSpec{
+		Plugins: PluginsSpec{
+			OMZ: OMZPluginList("git"),
+			Custom: PluginsList{
+				{ID: "autosuggestions", Name: "zsh-autosuggestions", Repo: "zsh-users/zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true},
+				{ID: "fzf-tab", Name: "fzf-tab", Repo: "Aloxaf/fzf-tab", Kind: PLUGIN_GITHUB, Enabled: false},
+			},
+		},
+		Source: SourceSpec{
+			Pre:  ValueListOf("~/.p10k-instant-prompt.zsh"),
+			Post: ValueListOf("~/.p10k.zsh"),
+		},
+	}
+
+	rendered, err := renderWithFacts(cfg, testFacts)
+	assert.NoError(t, err)
+
+	assertInOrder(t, rendered,
+		fmt.Sprintf("export ZSH_CUSTOM=%q", filepath.Join(env.OMZ(), "custom")),
+		`source ~/".p10k-instant-prompt.zsh"`,
+		fmt.Sprintf("fpath+=(\n\t%q", filepath.Join(env.Plugins(), "zsh-autosuggestions")),
+		"\t\tzsh-autosuggestions\n",
+		"source $ZSH/oh-my-zsh.sh",
+		`source ~/".p10k.zsh"`,
+	)
+	assert.NotContains(t, rendered, "fzf-tab")
+	assert.NotContains(t, rendered, "\t\tautosuggestions\n", "plugins are loaded by their directory name")
+}
+
+func TestRender_TemplateOverrides(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	write := func(path, content string) {
+		assert.NoError(t, afero.WriteFile(factory.Default.Fs, path, []byte(content), 0644))
+	}
+
+	cfg := &ConfigSpec{
+		Theme:   "simple",
+		Aliases: KeyValues{{Key: "k", Value: "kubectl"}},
+	}
+
+	write(filepath.Join(TemplatesDir(), "10-proxy.tmpl"), `{{ define "aliases" }}
+# PROXY
+{{- if eq os "darwin" }}
+export HTTPS_PROXY={{ quote "http://proxy:3128" }}
+{{- end }}
+{{- range .Config.Aliases }}
+alias {{ .Key }}={{ quote .Value }}
+{{- end }}
+{{ end }}`)
+
+	rendered, err := renderWithFacts(cfg, testFacts)
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, "ZSH_THEME=\"simple\"", "blocks which are not overridden are kept")
+	assertInOrder(t, rendered, "# PROXY", "export HTTPS_PROXY=http://proxy:3128", "alias k=kubectl")
+	assert.NotContains(t, rendered, "# ALIASES")
+
+	write("/tmp/zshrc.tmpl", `export EDITOR={{ env "EDITOR" }} # {{ join "," .OMZPlugins }}{{ block "aliases" . }}{{ end }}`)
+
+	rendered, err = renderWithFacts(cfg, testFacts, WithTemplate("/tmp/zshrc.tmpl"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rendered, "export EDITOR=nvim # \n# PROXY"), rendered)
+	assert.NotContains(t, rendered, "ZSH_THEME")
+
+	write("/tmp/invalid.tmpl", `{{ if }}`)
+	_, err = renderWithFacts(cfg, testFacts, WithTemplate("/tmp/invalid.tmpl"))
+	assert.Error(t, err)
+}
+
+func TestRender_Frameworks(t *testing.T) {
+	withMemFs(t, "/dfctl/dfctl.yaml")
+	for _, file := range []string{
+		filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh"),
+		filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh"),
+		filepath.Join(env.Plugins(), "zsh-completions", "zsh-completions.plugin.zsh"),
+		filepath.Join(env.Plugins(), "pure", "async.zsh"),
+		filepath.Join(env.Themes(), "powerlevel10k", "powerlevel10k.zsh-theme"),
+	} {
+		assert.NoError(t, afero.WriteFile(factory.Default.Fs, file, nil, 0644))
+	}
+
+	cfg := &ConfigSpec{
+		Theme: "powerlevel10k/powerlevel10k",
+		Plugins: PluginsSpec{
+			OMZ: OMZPluginList("git"),
+			Custom: PluginsList{
+				{ID: "zsh-autosuggestions", Name: "zsh-autosuggestions", Kind: PLUGIN_GITHUB, Enabled: true},
+				{ID: "zsh-completions", Name: "zsh-completions", Kind: PLUGIN_GITHUB, Enabled: true},
+				{ID: "pure", Name: "pure", Kind: PLUGIN_GITHUB, Enabled: true},
+			},
+		},
+		Themes: ThemesSpec{
+			{ID: "powerlevel10k/powerlevel10k", Name: "powerlevel10k", Repo: "romkatv/powerlevel10k", Kind: PLUGIN_GITHUB},
+		},
+	}
+
+	t.Run("none", func(t *testing.T) {
+		cfg.Framework = "plain"
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+
+		assert.NotContains(t, rendered, "oh-my-zsh.sh")
+		assert.NotContains(t, rendered, "ZSH_THEME")
+		assertInOrder(t, rendered,
+			fmt.Sprintf("%q", filepath.Join(env.OMZ(), "plugins", "git")),
+			"autoload -Uz compinit && compinit",
+			fmt.Sprintf("source %q", filepath.Join(env.OMZ(), "plugins", "git", "git.plugin.zsh")),
+			fmt.Sprintf("source %q", filepath.Join(env.Plugins(), "zsh-autosuggestions", "zsh-autosuggestions.plugin.zsh")),
+			fmt.Sprintf("source %q", filepath.Join(env.Plugins(), "zsh-completions", "zsh-completions.plugin.zsh")),
+			fmt.Sprintf("source %q", filepath.Join(env.Themes(), "powerlevel10k", "powerlevel10k.zsh-theme")),
+		)
+		assert.NotContains(t, rendered, "async.zsh", "plugins without init file are skipped")
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		cfg.Framework = FRAMEWORK_BUILTIN
+		rendered, err := renderWithFacts(cfg, testFacts)
+		assert.NoError(t, err)
+
+		assert.NotContains(t, rendered, "oh-my-zsh.sh")
+		assertInOrder(t, rendered,
+			"autoload -Uz compinit && compinit",
+			"_dfctl_load() {",
+			fmt.Sprintf("_dfctl_load %q", filepath.Join(env.OMZ(), "plugins", "git")),
+			fmt.Sprintf("_dfctl_load %q", filepath.Join(env.Plugins(), "pure")),
+			fmt.Sprintf("_dfctl_load %q", filepath.Join(env.Themes(), "powerlevel10k")),
+			"unfunction _dfctl_load",
+		)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		cfg.Framework = "prezto"
+		_, err := renderWithFacts(cfg, testFacts)
+		assert.ErrorIs(t, err, ErrUnknownFramework)
+	})
+}